@@ -0,0 +1,504 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/pkg/errors"
+	"github.com/tommyknows/gitlab-cli/pkg/gitlab"
+	"github.com/tommyknows/gitlab-cli/pkg/log"
+	"github.com/tommyknows/gitlab-cli/pkg/secret"
+	gogitlab "github.com/xanzy/go-gitlab"
+	"golang.org/x/oauth2"
+)
+
+// ResolveToken returns the token of the authentication, resolving it from
+// its source if needed. The token is only resolved once.
+func (a *Authentication) ResolveToken() (string, error) {
+	if a.token != "" {
+		return a.token, nil
+	}
+
+	var (
+		token string
+		err   error
+	)
+
+	switch {
+	case a.Type == Token && a.TokenAuthentication != nil:
+		token = a.TokenAuthentication.Token
+
+	case a.Type == TokenEnv && a.EnvAuthentication != nil:
+		token, err = readEnvSecret(a.TokenEnv)
+
+	case a.Type == TokenCommand && a.CommandAuthentication != nil:
+		token, err = runSecretCommand(a.TokenCommand)
+
+	case a.Type == TokenStore && a.StoreAuthentication != nil:
+		token, err = readStoreSecret(a.TokenStoreFile, a.TokenStoreKey)
+
+	case a.Type == JobToken:
+		var ok bool
+		if token, ok = os.LookupEnv(JobTokenEnv); !ok {
+			err = errors.Errorf("environment variable %v is not set, not running in Gitlab CI?", JobTokenEnv)
+		}
+
+	default:
+		err = errors.Errorf("authentication type %q does not provide a token", a.Type)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if token == "" {
+		return "", errors.Errorf("empty token from %v", a.Source())
+	}
+
+	a.token = token
+	return token, nil
+}
+
+// ResolvePassword returns the password of a basic-auth authentication,
+// resolving it from its source if needed. The password is only resolved once.
+func (a *Authentication) ResolvePassword() (string, error) {
+	if a.token != "" {
+		return a.token, nil
+	}
+
+	b := a.BasicAuthentication
+	if a.Type != BasicAuth || b == nil {
+		return "", errors.Errorf("authentication type %q does not provide a password", a.Type)
+	}
+
+	var (
+		password string
+		err      error
+	)
+
+	switch {
+	case b.PasswordEnv != "":
+		password, err = readEnvSecret(b.PasswordEnv)
+	case b.PasswordCommand != "":
+		password, err = runSecretCommand(b.PasswordCommand)
+	case b.PasswordStoreFile != "":
+		password, err = readStoreSecret(b.PasswordStoreFile, b.PasswordStoreKey)
+	default:
+		password = b.Password
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if password == "" {
+		return "", errors.Errorf("empty password from %v", a.Source())
+	}
+
+	a.token = password
+	return password, nil
+}
+
+// ReplaceToken replaces the token of the authentication, e.g. after it has
+// been rotated. Tokens that are read from the environment or a command
+// cannot be replaced, as they are managed outside of the CLI.
+func (a *Authentication) ReplaceToken(token string) error {
+	if !a.TokenReplaceable() {
+		return errors.Errorf("cannot replace token from %v", a.Source())
+	}
+
+	switch {
+	case a.Type == Token && a.TokenAuthentication != nil:
+		a.TokenAuthentication.Token = token
+
+	case a.Type == TokenStore && a.StoreAuthentication != nil:
+		passphrase, err := secret.ReadPassphrase("passphrase for " + a.TokenStoreFile + ": ")
+		if err != nil {
+			return err
+		}
+
+		store, err := secret.Open(a.TokenStoreFile, passphrase)
+		if err != nil {
+			return err
+		}
+
+		store.Set(a.TokenStoreKey, token)
+		if err := store.Write(); err != nil {
+			return err
+		}
+	}
+
+	a.token = token
+	return nil
+}
+
+// TokenReplaceable returns true if the token can be replaced with ReplaceToken.
+func (a *Authentication) TokenReplaceable() bool {
+	return (a.Type == Token && a.TokenAuthentication != nil) ||
+		(a.Type == TokenStore && a.StoreAuthentication != nil)
+}
+
+// Source describes where the secret of the authentication comes from,
+// without revealing it.
+func (a *Authentication) Source() string {
+	switch {
+	case a.Type == TokenEnv && a.EnvAuthentication != nil:
+		return "env $" + a.TokenEnv
+	case a.Type == TokenCommand && a.CommandAuthentication != nil:
+		return "command " + a.TokenCommand
+	case a.Type == TokenStore && a.StoreAuthentication != nil:
+		return "store " + a.TokenStoreFile
+	case a.Type == JobToken:
+		return "env $" + JobTokenEnv
+	case a.Type == BasicAuth && a.BasicAuthentication != nil:
+		return a.BasicAuthentication.source()
+	default:
+		return "config file"
+	}
+}
+
+func (b *BasicAuthentication) source() string {
+	switch {
+	case b.PasswordEnv != "":
+		return "env $" + b.PasswordEnv
+	case b.PasswordCommand != "":
+		return "command " + b.PasswordCommand
+	case b.PasswordStoreFile != "":
+		return "store " + b.PasswordStoreFile
+	default:
+		return "config file"
+	}
+}
+
+func readEnvSecret(env string) (string, error) {
+	s, ok := os.LookupEnv(env)
+	if !ok {
+		return "", errors.Errorf("environment variable %v is not set", env)
+	}
+	return s, nil
+}
+
+func runSecretCommand(command string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", errors.Wrapf(err, "secret command failed: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	// password managers usually print the secret on the first line.
+	return strings.TrimSpace(strings.SplitN(stdout.String(), "\n", 2)[0]), nil
+}
+
+func readStoreSecret(file, key string) (string, error) {
+	passphrase, err := secret.ReadPassphrase("passphrase for " + file + ": ")
+	if err != nil {
+		return "", err
+	}
+
+	store, err := secret.Open(file, passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	s, ok := store.Get(key)
+	if !ok {
+		return "", errors.Errorf("no secret %q in store %v", key, file)
+	}
+
+	return s, nil
+}
+
+// JobTokenEnv is the environment variable that holds the
+// job token in Gitlab CI.
+const JobTokenEnv = "CI_JOB_TOKEN"
+
+// guardedClient creates a Gitlab client with the given namespace as
+// root, whose requests are checked by the guard, if it is not nil.
+func (ic *InstanceConfig) guardedClient(namespace string, guard *gitlab.Guard) (*gitlab.Client, error) {
+	httpClient, err := ic.HTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if guard != nil {
+		httpClient.Transport = guard.Transport(httpClient.Transport)
+	}
+
+	cl, err := ic.apiClient(httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return gitlab.New(cl, namespace), nil
+}
+
+// apiClient creates a go-gitlab client that authenticates with the
+// authentication of the instance.
+func (ic *InstanceConfig) apiClient(httpClient *http.Client) (*gogitlab.Client, error) {
+	a := ic.Authentication
+	if a == nil {
+		return nil, errors.Errorf("no authentication configured for instance %v", ic.URL)
+	}
+
+	opts := []gogitlab.ClientOptionFunc{
+		gogitlab.WithBaseURL(ic.apiURL()),
+		gogitlab.WithHTTPClient(httpClient),
+	}
+
+	switch a.Type {
+	case BasicAuth:
+		token, err := ic.passwordGrantToken(httpClient)
+		if err != nil {
+			return nil, err
+		}
+		return gogitlab.NewOAuthClient(token, opts...)
+
+	case OAuth2:
+		token, err := ic.oauth2Token(httpClient, false)
+		if err != nil {
+			return nil, err
+		}
+		if a.RefreshToken != "" {
+			httpClient.Transport = &oauth2Transport{ic: ic, next: httpClient.Transport}
+		}
+		return gogitlab.NewOAuthClient(token, opts...)
+
+	case JobToken:
+		token, err := a.ResolveToken()
+		if err != nil {
+			return nil, err
+		}
+		httpClient.Transport = &jobTokenTransport{token: token, next: httpClient.Transport}
+		return gogitlab.NewClient("", opts...)
+
+	default:
+		token, err := a.ResolveToken()
+		if err != nil {
+			return nil, err
+		}
+		return gogitlab.NewClient(token, opts...)
+	}
+}
+
+// gitAuth returns the authentication for git operations over HTTP.
+func (ic *InstanceConfig) gitAuth() (transport.AuthMethod, error) {
+	a := ic.Authentication
+	if a == nil {
+		return nil, errors.Errorf("no authentication configured for instance %v", ic.URL)
+	}
+
+	switch a.Type {
+	case BasicAuth:
+		if a.BasicAuthentication == nil {
+			return nil, errors.New("no username and password configured")
+		}
+		password, err := a.ResolvePassword()
+		if err != nil {
+			return nil, err
+		}
+		return &githttp.BasicAuth{Username: a.Username, Password: password}, nil
+
+	case OAuth2:
+		httpClient, err := ic.HTTPClient()
+		if err != nil {
+			return nil, err
+		}
+
+		token, err := ic.oauth2Token(httpClient, false)
+		if err != nil {
+			return nil, err
+		}
+		return &githttp.BasicAuth{Username: "oauth2", Password: token}, nil
+
+	case JobToken:
+		token, err := a.ResolveToken()
+		if err != nil {
+			return nil, err
+		}
+		return &githttp.BasicAuth{Username: "gitlab-ci-token", Password: token}, nil
+
+	default:
+		token, err := a.ResolveToken()
+		if err != nil {
+			return nil, err
+		}
+		return &githttp.BasicAuth{Username: "token", Password: token}, nil
+	}
+}
+
+// passwordGrantToken exchanges the username and password of the instance
+// for an OAuth2 access token. Unlike go-gitlab's basic-auth client, it
+// keeps the relative URL root of the instance.
+func (ic *InstanceConfig) passwordGrantToken(httpClient *http.Client) (string, error) {
+	a := ic.Authentication
+	if a.BasicAuthentication == nil {
+		return "", errors.New("no username and password configured")
+	}
+
+	password, err := a.ResolvePassword()
+	if err != nil {
+		return "", err
+	}
+
+	oauthCfg := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  ic.oauthURL("authorize"),
+			TokenURL: ic.oauthURL("token"),
+		},
+	}
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpClient)
+	token, err := oauthCfg.PasswordCredentialsToken(ctx, a.Username, password)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not get OAuth2 token for user %v", a.Username)
+	}
+
+	return token.AccessToken, nil
+}
+
+// oauth2Token returns a valid OAuth2 access token, refreshing it if it
+// has expired. If rejected is set, Gitlab has rejected the token and it
+// is refreshed regardless of its expiry. Refreshed tokens are persisted
+// immediately.
+func (ic *InstanceConfig) oauth2Token(httpClient *http.Client, rejected bool) (string, error) {
+	a := ic.Authentication
+	if a.OAuth2Authentication == nil || a.AccessToken == "" {
+		return "", errors.New("no OAuth2 access token configured")
+	}
+
+	oauthCfg := &oauth2.Config{
+		ClientID:     a.ClientID,
+		ClientSecret: a.ClientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  ic.oauthURL("authorize"),
+			TokenURL: ic.oauthURL("token"),
+		},
+	}
+
+	current := &oauth2.Token{
+		AccessToken:  a.AccessToken,
+		RefreshToken: a.RefreshToken,
+	}
+	if a.Expiry != nil {
+		current.Expiry = *a.Expiry
+	}
+	if rejected {
+		current.Expiry = time.Now().Add(-time.Minute)
+	}
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpClient)
+	token, err := oauthCfg.TokenSource(ctx, current).Token()
+	if err != nil {
+		return "", errors.Wrapf(err, "could not refresh OAuth2 token")
+	}
+
+	if token.AccessToken != a.AccessToken {
+		log.Debugf("refreshed OAuth2 token for instance %v", ic.URL)
+
+		a.AccessToken = token.AccessToken
+		a.Expiry = nil
+		if !token.Expiry.IsZero() {
+			a.Expiry = &token.Expiry
+		}
+		if token.RefreshToken != "" {
+			a.RefreshToken = token.RefreshToken
+		}
+
+		if ic.persist != nil {
+			if err := ic.persist(); err != nil {
+				return "", errors.Wrapf(err, "could not store refreshed OAuth2 token")
+			}
+		}
+	}
+
+	return token.AccessToken, nil
+}
+
+func (ic *InstanceConfig) oauthURL(endpoint string) string {
+	u := ic.BaseURL()
+	u.Path = path.Join(u.Path, "/oauth", endpoint)
+	return u.String()
+}
+
+// oauth2Transport authenticates requests with the OAuth2 access token of
+// the instance. As the expiry of a token may be unknown, a token that is
+// rejected by Gitlab is refreshed and the request is retried once.
+type oauth2Transport struct {
+	ic   *InstanceConfig
+	next http.RoundTripper
+	// mu guards the token of the instance, which is
+	// refreshed by the first rejected request.
+	mu sync.Mutex
+}
+
+func (t *oauth2Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	token := t.ic.Authentication.AccessToken
+	t.mu.Unlock()
+
+	resp, err := t.next.RoundTrip(withBearerToken(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	t.mu.Lock()
+	if t.ic.Authentication.AccessToken == token {
+		// the refresh must not be authenticated with the access token.
+		_, err = t.ic.oauth2Token(&http.Client{Transport: t.next}, true)
+	}
+	refreshed := t.ic.Authentication.AccessToken
+	t.mu.Unlock()
+
+	if err != nil {
+		log.Debugf("could not refresh rejected OAuth2 token: %v", err)
+		return resp, nil
+	}
+
+	retry := withBearerToken(req, refreshed)
+	if req.Body != nil {
+		// the body has been consumed, it can only be sent again if it
+		// can be recreated.
+		if req.GetBody == nil {
+			return resp, nil
+		}
+		if retry.Body, err = req.GetBody(); err != nil {
+			return resp, nil
+		}
+	}
+
+	resp.Body.Close()
+	return t.next.RoundTrip(retry)
+}
+
+func withBearerToken(req *http.Request, token string) *http.Request {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+// jobTokenTransport authenticates requests with a CI job token.
+type jobTokenTransport struct {
+	token string
+	next  http.RoundTripper
+}
+
+func (t *jobTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Del("PRIVATE-TOKEN")
+	req.Header.Set("JOB-TOKEN", t.token)
+	return t.next.RoundTrip(req)
+}
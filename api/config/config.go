@@ -5,37 +5,243 @@ import (
 	"io/ioutil"
 	"net/url"
 	"os"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/ghodss/yaml"
 	"github.com/pkg/errors"
+	"github.com/tommyknows/gitlab-cli/pkg/gitlab"
+	"github.com/tommyknows/gitlab-cli/pkg/log"
 )
 
 // Config defines the structure of the config
 // as a file.
 type Config struct {
+	// APIVersion is the version of the config file format. Files of older
+	// versions are migrated when they are loaded.
+	APIVersion string `json:"apiVersion"`
+
 	Instances Instances           `json:"instances"`
 	Contexts  map[string]*Context `json:"contexts"`
 
 	CurrentContext string `json:"currentContext"`
 
+	// History holds the previously used contexts, the most recent first.
+	History []HistoryEntry `json:"history,omitempty"`
+
+	// TokenExpiryWarningDays is the number of days before the token of
+	// the current context expires, from which on a warning is printed.
+	// Defaults to DefaultTokenExpiryWarningDays, negative values disable it.
+	TokenExpiryWarningDays int `json:"tokenExpiryWarningDays,omitempty"`
+
 	// PreferConfigContext indicates if the `currentContext` should take
 	// precedence over the (filesystem-)local git repository.
 	PreferConfigContext bool `json:"preferContext,omitempty"`
 
+	// GitRemote is the remote of local git repositories that is checked
+	// first when detecting the context, e.g. "upstream" for forks.
+	// Defaults to "origin".
+	GitRemote string `json:"gitRemote,omitempty"`
+
 	name string // the file's name
-	// if (filesystem-)local git repositories  should be ignored
+	// if (filesystem-)local git repositories and pins should be ignored
 	useConfigContext bool
+	// if only (filesystem-)local git repositories should be ignored
+	ignoreLocalRepository bool
+	// the context to use for this invocation, instead of CurrentContext
+	contextOverride string
+	// the git remote to use for this invocation, instead of GitRemote
+	remoteOverride string
+	// asks before changes through contexts that require a confirmation
+	confirm gitlab.ConfirmFunc
+	// if the expiry of the token has been checked in this invocation
+	expiryChecked bool
+
+	// file is shared between copies of the config, so that all
+	// of them know what has been read from / written to disk.
+	file *fileState
+	// layers is set instead of file if multiple files have been loaded.
+	layers *layers
+}
+
+// DefaultTokenExpiryWarningDays is used if TokenExpiryWarningDays is not set.
+const DefaultTokenExpiryWarningDays = 7
+
+// TokenExpiryWarning returns the duration before the expiry of
+// a token, from which on users should be warned.
+func (c *Config) TokenExpiryWarning() time.Duration {
+	days := c.TokenExpiryWarningDays
+	if days == 0 {
+		days = DefaultTokenExpiryWarningDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// warnTokenExpiry logs a warning if the token of the context's instance
+// is about to expire. It is only checked for the first context that is
+// resolved in an invocation.
+func (c *Config) warnTokenExpiry(ctx *Context) {
+	if c.expiryChecked || c.TokenExpiryWarningDays < 0 {
+		return
+	}
+	c.expiryChecked = true
+
+	expiresAt := ctx.Instance().TokenExpiresAt
+	if expiresAt == nil || time.Until(*expiresAt) > c.TokenExpiryWarning() {
+		return
+	}
+
+	if time.Now().After(*expiresAt) {
+		log.Warningf("the token of instance %v expired on %v", ctx.InstanceName, expiresAt.Format("2006-01-02"))
+		return
+	}
+
+	log.Warningf("the token of instance %v expires on %v, rotate it with \"gitlab-cli instance rotate-token %v\"",
+		ctx.InstanceName, expiresAt.Format("2006-01-02"), ctx.InstanceName)
 }
 
 type Instances map[string]*InstanceConfig
 
 type InstanceConfig struct {
+	// URL is the base URL of the Gitlab instance. It includes the scheme,
+	// an optional port and the relative URL root if Gitlab is not served
+	// from the root of the host, e.g. http://gitlab.internal:8080/gitlab
+	URL            string          `json:"url,omitempty"`
 	Authentication *Authentication `json:"authentication,omitempty"`
-	url            *url.URL
+	TLS            *TLSConfig      `json:"tls,omitempty"`
+
+	// Namespaces limits the local repositories that are detected as
+	// projects of this instance, if several instances share a host, e.g.
+	// a personal and a work account on gitlab.com. A repository matches
+	// if its project is within one of the namespaces.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Preferred marks the instance that is used for local repositories
+	// if several instances share a host and none of their namespaces
+	// match the project.
+	Preferred bool `json:"preferred,omitempty"`
+
+	// TokenExpiresAt records the expiry of the access token, as it has
+	// been reported by Gitlab when the token was last validated.
+	TokenExpiresAt *time.Time `json:"tokenExpiresAt,omitempty"`
+
+	url *url.URL
+
+	// persist writes the config that this instance belongs to. It is
+	// used to store refreshed OAuth2 tokens right away, as the old
+	// refresh token is invalid after it has been used.
+	persist func() error
+}
+
+// NewInstanceConfig creates an instance config for the Gitlab instance
+// at rawURL. See ParseInstanceURL for the accepted formats.
+func NewInstanceConfig(rawURL string, auth *Authentication) (*InstanceConfig, error) {
+	u, err := ParseInstanceURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InstanceConfig{
+		URL:            u.String(),
+		Authentication: auth,
+		url:            u,
+	}, nil
+}
+
+// ParseInstanceURL parses the URL of a Gitlab instance. If no scheme is
+// given, https is assumed. Trailing slashes are removed from the path.
+func ParseInstanceURL(rawURL string) (*url.URL, error) {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse instance URL %q", rawURL)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("unsupported scheme %q in instance URL %q", u.Scheme, rawURL)
+	}
+
+	if u.Host == "" {
+		return nil, errors.Errorf("no host in instance URL %q", rawURL)
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawQuery, u.Fragment = "", ""
+	return u, nil
+}
+
+// withAuthentication returns a copy of the instance config that uses
+// the given authentication. Changes to the copy are not persisted.
+func (ic *InstanceConfig) withAuthentication(auth *Authentication) *InstanceConfig {
+	i := *ic
+	i.Authentication = auth
+	i.TokenExpiresAt = nil
+	i.persist = nil
+	return &i
+}
+
+// BaseURL returns a copy of the URL of the instance.
+func (ic *InstanceConfig) BaseURL() *url.URL {
+	u := *ic.url
+	return &u
 }
 
 func (ic *InstanceConfig) apiURL() string {
-	return "https://" + ic.url.Host + "/api/v4"
+	u := ic.BaseURL()
+	u.Path = path.Join(u.Path, "/api/v4")
+	return u.String()
+}
+
+// namespaceOf returns the namespace that the given (git remote) URL is
+// pointing to, if it is hosted on this instance. For HTTP URLs, the
+// relative URL root of the instance is stripped from the path. The
+// port is not compared, as SSH remotes usually don't share the port
+// with the web interface.
+func (ic *InstanceConfig) namespaceOf(u *url.URL) (string, bool) {
+	if !strings.EqualFold(u.Hostname(), ic.url.Hostname()) {
+		return "", false
+	}
+
+	isHTTP := u.Scheme == "http" || u.Scheme == "https"
+	if isHTTP && ic.url.Path != "" && strings.HasPrefix(u.Path, ic.url.Path+"/") {
+		return strings.TrimPrefix(u.Path, ic.url.Path), true
+	}
+
+	return u.Path, true
+}
+
+// matchPriority ranks the instance for a project on its host, in case
+// several instances share the host. Instances with a namespace that
+// contains the project rank highest, the longest namespace first. They
+// are followed by the preferred instance and by instances without any
+// namespaces. Instances whose namespaces don't contain the project rank
+// last.
+func (ic *InstanceConfig) matchPriority(projectPath string) int {
+	projectPath = strings.ToLower(strings.Trim(projectPath, "/"))
+
+	priority := -1
+	for _, ns := range ic.Namespaces {
+		ns = strings.ToLower(strings.Trim(ns, "/"))
+		if ns == "" || (projectPath != ns && !strings.HasPrefix(projectPath, ns+"/")) {
+			continue
+		}
+		if p := 3 + len(ns); p > priority {
+			priority = p
+		}
+	}
+
+	switch {
+	case priority != -1:
+		return priority
+	case ic.Preferred:
+		return 2
+	case len(ic.Namespaces) == 0:
+		return 1
+	}
+	return 0
 }
 
 func (i *Instances) UnmarshalJSON(data []byte) error {
@@ -44,20 +250,33 @@ func (i *Instances) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &a); err != nil {
 		return errors.Wrapf(err, "could not unmarshal instance")
 	}
-	for iURL := range *i {
-		u, err := url.Parse("https://" + iURL)
+	for name, instance := range *i {
+		if instance.URL == "" {
+			return errors.Errorf("instance %v has no URL", name)
+		}
+
+		u, err := ParseInstanceURL(instance.URL)
 		if err != nil {
-			return errors.Wrapf(err, "could not parse instance %v", iURL)
+			return errors.Wrapf(err, "could not parse instance %v", name)
 		}
-		(*i)[iURL].url = u
+		instance.URL = u.String()
+		instance.url = u
 	}
 	return nil
 }
 
 type Authentication struct {
-	Type                 AuthenticationType `json:"type"`
-	*TokenAuthentication `json:",omitempty"`
-	*BasicAuthentication `json:",omitempty"`
+	Type                   AuthenticationType `json:"type"`
+	*TokenAuthentication   `json:",omitempty"`
+	*BasicAuthentication   `json:",omitempty"`
+	*EnvAuthentication     `json:",omitempty"`
+	*CommandAuthentication `json:",omitempty"`
+	*StoreAuthentication   `json:",omitempty"`
+	*OAuth2Authentication  `json:",omitempty"`
+
+	// the resolved token (or basic-auth password), so that secrets
+	// are only looked up once.
+	token string
 }
 
 type AuthenticationType string
@@ -65,65 +284,192 @@ type AuthenticationType string
 const (
 	Token     AuthenticationType = "token"
 	BasicAuth AuthenticationType = "basic-auth"
+
+	// TokenEnv, TokenCommand and TokenStore are token authentications
+	// where the token is not stored in the config file, but resolved
+	// when it is needed.
+	TokenEnv     AuthenticationType = "token-env"
+	TokenCommand AuthenticationType = "token-command"
+	TokenStore   AuthenticationType = "token-store"
+
+	// OAuth2 authenticates with an OAuth2 access token, which is refreshed
+	// with the refresh token once it expires.
+	OAuth2 AuthenticationType = "oauth2"
+	// JobToken authenticates with the CI_JOB_TOKEN of a Gitlab CI job.
+	JobToken AuthenticationType = "job-token"
 )
 
 type Context struct {
 	// the "default" namespace to use, a.k.a. the root of every operation.
 	Namespace    string `json:"namespace,omitempty"`
 	InstanceName string `json:"instance"`
+	// Protection guards the instance against changes through this
+	// context, e.g. for production instances.
+	Protection gitlab.Protection `json:"protection,omitempty"`
 
 	// this is not populated at unmarshal because we cannot rely on
 	// the order of unmarshaling.
 	instanceConfig *InstanceConfig
+	// ephemeral contexts are not stored in the config file.
+	ephemeral bool
+	// the name of the context in the config, if it is not ephemeral.
+	name string
+	// the local git repository the context has been detected from, if any.
+	repository *LocalRepository
+	// the project the context refers to, once it has been looked up.
+	project *gitlab.ProjectRef
+	// guard enforces the protection on the clients of the context.
+	guard *gitlab.Guard
 }
 
 type TokenAuthentication struct {
 	Token string `json:"token"`
 }
 
+// BasicAuthentication authenticates with a username and password. Like
+// a token, the password may be read from an environment variable, the
+// output of a command or the encrypted store instead of the config file.
 type BasicAuthentication struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username          string `json:"username"`
+	Password          string `json:"password,omitempty"`
+	PasswordEnv       string `json:"passwordEnv,omitempty"`
+	PasswordCommand   string `json:"passwordCommand,omitempty"`
+	PasswordStoreFile string `json:"passwordStoreFile,omitempty"`
+	PasswordStoreKey  string `json:"passwordStoreKey,omitempty"`
+}
+
+// OAuth2Authentication holds an OAuth2 token. The client ID (and secret,
+// for confidential applications) is needed to refresh the token. Refreshed
+// tokens are written back to the config file.
+type OAuth2Authentication struct {
+	AccessToken  string     `json:"accessToken"`
+	RefreshToken string     `json:"refreshToken,omitempty"`
+	Expiry       *time.Time `json:"expiry,omitempty"`
+	ClientID     string     `json:"clientID,omitempty"`
+	ClientSecret string     `json:"clientSecret,omitempty"`
+}
+
+// EnvAuthentication reads the token from an environment variable.
+type EnvAuthentication struct {
+	TokenEnv string `json:"tokenEnv"`
+}
+
+// CommandAuthentication runs a shell command and uses its output as the
+// token, similar to git's credential helpers. This allows to retrieve the
+// token from password managers like pass or vault.
+type CommandAuthentication struct {
+	TokenCommand string `json:"tokenCommand"`
+}
+
+// StoreAuthentication reads the token from an encrypted file that is
+// protected by a passphrase.
+type StoreAuthentication struct {
+	TokenStoreFile string `json:"tokenStoreFile"`
+	TokenStoreKey  string `json:"tokenStoreKey"`
 }
 
 func Default() *Config {
 	return &Config{
-		Instances: make(map[string]*InstanceConfig),
-		Contexts:  make(map[string]*Context),
+		APIVersion: CurrentAPIVersion,
+		Instances:  make(map[string]*InstanceConfig),
+		Contexts:   make(map[string]*Context),
+		file:       new(fileState),
 	}
 }
 
-// Load tries to load a config from file. allocates a file if it should not exist
+// Load tries to load a config from file. If the file does not exist,
+// it is created on the first write.
 func Load(filename string, useConfigContext bool) (*Config, error) {
-	if stat, _ := os.Stat(filename); stat == nil {
-		defaultConfig := Default()
-		defaultConfig.name = filename
-
-		def, _ := yaml.Marshal(defaultConfig)
-		return defaultConfig, ioutil.WriteFile(filename, def, 0600)
-	}
+	c := Default()
+	c.name = filename
+	c.useConfigContext = useConfigContext
 
 	cont, err := ioutil.ReadFile(filename)
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		return nil, errors.Wrapf(err, "could not read config file")
 	}
 
-	c := Default()
-	if err := yaml.Unmarshal(cont, c); err != nil {
-		return nil, errors.Wrapf(err, "could not unmarshal config")
+	if err == nil {
+		if err := c.unmarshal(cont); err != nil {
+			return nil, err
+		}
+		c.file.content = cont
+		c.file.exists = true
 	}
 
-	c.name = filename
-	c.useConfigContext = useConfigContext
+	for _, instance := range c.Instances {
+		instance.persist = c.Write
+	}
 
 	return c, nil
 }
 
+// unmarshal unmarshals the content of a config file into c,
+// migrating it to the current version if needed.
+func (c *Config) unmarshal(cont []byte) error {
+	raw, err := parseRaw(cont)
+	if err != nil {
+		return err
+	}
+	return c.unmarshalRaw(raw)
+}
+
+// parseRaw parses the content of a config file into a generic map,
+// migrating it to the current version if needed.
+func parseRaw(cont []byte) (map[string]interface{}, error) {
+	j, err := yaml.YAMLToJSON(cont)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse config")
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(j, &raw); err != nil {
+		return nil, errors.Wrapf(err, "could not parse config")
+	}
+
+	if raw == nil {
+		raw = make(map[string]interface{})
+	}
+
+	if err := migrate(raw); err != nil {
+		return nil, errors.Wrapf(err, "could not migrate config")
+	}
+
+	return raw, nil
+}
+
+func (c *Config) unmarshalRaw(raw map[string]interface{}) error {
+	j, err := json.Marshal(raw)
+	if err != nil {
+		return errors.Wrapf(err, "could not marshal migrated config")
+	}
+
+	if err := json.Unmarshal(j, c); err != nil {
+		return errors.Wrapf(err, "could not unmarshal config")
+	}
+
+	return nil
+}
+
+// Write writes the config to disk if it has changed since it has been
+// loaded. The file is locked while writing and replaced atomically. If
+// the file has been changed by another process in the meantime, an
+// ErrConcurrentModification is returned instead of overwriting it.
+//
+// If multiple files have been loaded with LoadFiles, the changes are
+// written to the files that they have been taken from, see LoadFiles.
 func (c *Config) Write() error {
+	if c.layers != nil {
+		return c.layers.write(c)
+	}
+
 	cont, err := yaml.Marshal(c)
 	if err != nil {
 		return errors.Wrapf(err, "could not marshal config")
 	}
 
-	return ioutil.WriteFile(c.name, cont, 0600)
+	if c.file == nil {
+		c.file = new(fileState)
+	}
+	return c.file.write(c.name, cont)
 }
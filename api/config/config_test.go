@@ -0,0 +1,134 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadMigratesLegacyConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitlab-cli-config")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "config.yml")
+	legacy := `contexts:
+  gitlab.com:
+    instance: gitlab.com
+currentContext: gitlab.com
+instances:
+  gitlab.com:
+    authentication:
+      token: secret
+      type: token
+`
+	if err := ioutil.WriteFile(name, []byte(legacy), 0600); err != nil {
+		t.Fatalf("could not write config: %v", err)
+	}
+
+	c, err := Load(name, false)
+	if err != nil {
+		t.Fatalf("could not load config: %v", err)
+	}
+
+	if c.APIVersion != CurrentAPIVersion {
+		t.Errorf("api version not correct. expected=%q, got=%q", CurrentAPIVersion, c.APIVersion)
+	}
+
+	if u := c.Instances["gitlab.com"].URL; u != "https://gitlab.com" {
+		t.Errorf("instance URL not migrated. expected=%q, got=%q", "https://gitlab.com", u)
+	}
+
+	if err := c.Write(); err != nil {
+		t.Fatalf("could not write migrated config: %v", err)
+	}
+
+	c, err = Load(name, false)
+	if err != nil {
+		t.Fatalf("could not load migrated config: %v", err)
+	}
+
+	if c.Instances["gitlab.com"].Authentication.TokenAuthentication.Token != "secret" {
+		t.Errorf("token lost during migration")
+	}
+
+	if err := ioutil.WriteFile(name, []byte("apiVersion: v999\n"), 0600); err != nil {
+		t.Fatalf("could not write config: %v", err)
+	}
+
+	if _, err := Load(name, false); err == nil {
+		t.Errorf("expected error loading config of unknown version, got=%v", err)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitlab-cli-config")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "config.yml")
+
+	c, err := Load(name, false)
+	if err != nil {
+		t.Fatalf("could not load config: %v", err)
+	}
+
+	if err := c.Write(); err != nil {
+		t.Fatalf("could not write new config: %v", err)
+	}
+
+	stat, err := os.Stat(name)
+	if err != nil {
+		t.Fatalf("config has not been written: %v", err)
+	}
+
+	// unchanged configs are not written.
+	if err := os.Chmod(name, 0400); err != nil {
+		t.Fatalf("could not change permissions: %v", err)
+	}
+	c, err = Load(name, false)
+	if err != nil {
+		t.Fatalf("could not load config: %v", err)
+	}
+	if err := c.Write(); err != nil {
+		t.Errorf("unchanged config has been written: %v", err)
+	}
+	if newStat, _ := os.Stat(name); !newStat.ModTime().Equal(stat.ModTime()) {
+		t.Errorf("unchanged config has been written")
+	}
+
+	// a concurrent modification must not be overwritten.
+	other, err := Load(name, false)
+	if err != nil {
+		t.Fatalf("could not load config: %v", err)
+	}
+
+	c.CurrentContext = "first"
+	if err := c.Write(); err != nil {
+		t.Fatalf("could not write config: %v", err)
+	}
+
+	other.CurrentContext = "second"
+	if err := other.Write(); err == nil {
+		t.Errorf("expected error on concurrent modification, got=%v", err)
+	} else if _, ok := err.(ErrConcurrentModification); !ok {
+		t.Errorf("wrong error on concurrent modification. expected=%T, got=%v", ErrConcurrentModification{}, err)
+	}
+
+	c, err = Load(name, false)
+	if err != nil {
+		t.Fatalf("could not load config: %v", err)
+	}
+	if c.CurrentContext != "first" {
+		t.Errorf("config has been clobbered. expected=%q, got=%q", "first", c.CurrentContext)
+	}
+
+	if _, err := os.Stat(name + lockSuffix); !os.IsNotExist(err) {
+		t.Errorf("lock file has not been removed: %v", err)
+	}
+}
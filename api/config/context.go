@@ -1,17 +1,17 @@
 package config
 
 import (
+	"context"
 	"fmt"
 	"net/url"
+	"os"
+	"sort"
 	"strings"
 
-	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing/transport"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/pkg/errors"
 	"github.com/tommyknows/gitlab-cli/pkg/gitlab"
 	"github.com/tommyknows/gitlab-cli/pkg/log"
-	gogitlab "github.com/xanzy/go-gitlab"
 )
 
 type ErrInvalidContext struct {
@@ -22,56 +22,279 @@ func (e ErrInvalidContext) Error() string {
 	return fmt.Sprintf("invalid context %q does not exist", e.name)
 }
 
-// GetGetCurrentContext returns the right Context depending on
-// the configuration. It considers the (filesystem-)local git
-// repository and tries to find an instance config that relates
-// to the git remote. If that exist, it constructs a new, temporary
-// context that points to that instance with the correct group set.
+const (
+	// ContextEnv selects the context for a single invocation, like --context.
+	ContextEnv = "GITLAB_CLI_CONTEXT"
+	// HostEnv and GitlabTokenEnv specify an instance (and its token) that
+	// is used instead of the configured contexts, without touching the
+	// config file. GitlabTokenEnv alone replaces the token of the instance
+	// of the current context.
+	HostEnv        = "GITLAB_HOST"
+	GitlabTokenEnv = "GITLAB_TOKEN"
+)
+
+// OverrideContext selects the context to use for this invocation,
+// without changing the current context of the config.
+func (c *Config) OverrideContext(name string) {
+	c.contextOverride = name
+}
+
+// IgnoreLocalRepository disables the detection of the (filesystem-)local
+// git repository for this invocation. Unlike --use-config-context, a
+// context pinned to the working directory is still used.
+func (c *Config) IgnoreLocalRepository() {
+	c.ignoreLocalRepository = true
+}
+
+// GetCurrentContext returns the right Context depending on the
+// configuration and environment. The first match of the following wins:
+//
+//  1. the context given by OverrideContext (--context) or $GITLAB_CLI_CONTEXT.
+//  2. an ephemeral context for the instance at $GITLAB_HOST, which
+//     authenticates with $GITLAB_TOKEN.
+//  3. unless the config context is preferred, the (filesystem-)local git
+//     repository: if one of its remotes relates to a known instance, a
+//     temporary context pointing to that instance and group is created.
+//  4. unless the config context is preferred, the context pinned by a
+//     PinFileName file in the working directory or one of its parents.
+//  5. the current context of the config file.
+//
+// If only $GITLAB_TOKEN is set, it replaces the token of the instance of
+// the resolved context, which is otherwise kept as it is. A warning is
+// logged if the token of the instance is about to expire.
 func (c *Config) GetCurrentContext() (*Context, error) {
+	ctx, err := c.resolveContext()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := os.LookupEnv(GitlabTokenEnv); ok {
+		log.Debugf("using token from $%v for instance %v", GitlabTokenEnv, ctx.InstanceName)
+		ctx = ctx.withInstance(ctx.instanceConfig.withAuthentication(envTokenAuthentication()))
+	}
+
+	c.protect(ctx)
+	c.warnTokenExpiry(ctx)
+	return ctx, nil
+}
+
+// ConfirmWith sets the function that asks whether changes may be made
+// through contexts that require a confirmation. Without it, such changes
+// are refused.
+func (c *Config) ConfirmWith(confirm gitlab.ConfirmFunc) {
+	c.confirm = confirm
+}
+
+// protect sets up the guard that enforces the protection of the context.
+// Ephemeral contexts get the strictest protection of the stored contexts
+// of their instance, so that an instance is also protected when it is
+// used through a local repository.
+func (c *Config) protect(ctx *Context) {
+	protection := ctx.Protection
+	if ctx.ephemeral {
+		protection = protection.Stricter(c.instanceProtection(ctx.InstanceName))
+	}
+
+	if protection != "" && protection != gitlab.Unprotected {
+		log.Debugf("context %v is protected: %v", ctx.describe(), protection)
+	}
+
+	ctx.guard = &gitlab.Guard{
+		Context:    ctx.describe(),
+		Protection: protection,
+		Confirm:    c.confirm,
+	}
+}
+
+// instanceProtection returns the strictest protection of the stored
+// contexts of the instance.
+func (c *Config) instanceProtection(instance string) gitlab.Protection {
+	var protection gitlab.Protection
+	for _, stored := range c.Contexts {
+		if stored.InstanceName == instance {
+			protection = protection.Stricter(stored.Protection)
+		}
+	}
+	return protection
+}
+
+// InstanceClient creates a Gitlab client for the instance, outside of a
+// context, with the given namespace as root. Its requests are protected
+// like the strictest context of the instance.
+func (c *Config) InstanceClient(name, namespace string) (*gitlab.Client, error) {
+	instance, ok := c.Instances[name]
+	if !ok {
+		return nil, errors.Errorf("no such instance: %q", name)
+	}
+
+	return instance.guardedClient(namespace, &gitlab.Guard{
+		Context:    fmt.Sprintf("of instance %v", name),
+		Protection: c.instanceProtection(name),
+		Confirm:    c.confirm,
+	})
+}
+
+func (c *Config) resolveContext() (*Context, error) {
+	if name := c.selectedContext(); name != "" {
+		log.Debugf("using context %q selected for this invocation", name)
+		return c.getConfigContext(name)
+	}
+
+	if host := os.Getenv(HostEnv); host != "" {
+		log.Debugf("using ephemeral context for $%v", HostEnv)
+		return c.newEnvContext(host)
+	}
+
 	if c.PreferConfigContext || c.useConfigContext {
 		log.Debugf("preferring config context")
 		return c.getCurrentConfigContext()
 	}
 
-	repo, err := git.PlainOpen(localPath)
-	if repo != nil && err != git.ErrRepositoryNotExists {
-		log.Debugf("currently in git repo, creating git context")
-		return c.newGitRepoContext(repo)
+	if !c.ignoreLocalRepository {
+		repo, err := findRepository(localPath)
+		switch {
+		case err == nil:
+			log.Debugf("currently in git repo %v, creating git context", repo.gitDir)
+			return c.newGitRepoContext(repo)
+
+		case err != errNoRepository:
+			return nil, errors.Wrapf(err, "could not open local git repository")
+		}
+	}
+
+	pin, path, err := findPin(localPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if pin != nil {
+		log.Debugf("using context pinned by %v", path)
+		ctx, err := c.newPinnedContext(pin)
+		return ctx, errors.Wrapf(err, "invalid pin file %v", path)
 	}
 
 	log.Debugf("not in git repo, using default context")
 	return c.getCurrentConfigContext()
 }
 
+// UseRemote selects the git remote of the local repository that is used
+// to detect the context for this invocation. Other remotes are ignored.
+func (c *Config) UseRemote(name string) {
+	c.remoteOverride = name
+}
+
+// selectedContext returns the name of the context that has been
+// selected for this invocation, if any.
+func (c *Config) selectedContext() string {
+	if c.contextOverride != "" {
+		return c.contextOverride
+	}
+	return os.Getenv(ContextEnv)
+}
+
+// newEnvContext creates an ephemeral context for the instance at host,
+// authenticating with the token from the environment. If the instance
+// is configured, its settings (e.g. TLS) are used.
+func (c *Config) newEnvContext(host string) (*Context, error) {
+	inst, err := NewInstanceConfig(host, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid $%v", HostEnv)
+	}
+
+	if _, ok := os.LookupEnv(GitlabTokenEnv); !ok {
+		return nil, errors.Errorf("$%v is set, but $%v is not", HostEnv, GitlabTokenEnv)
+	}
+
+	name := inst.BaseURL().Host
+	for instName, instCfg := range c.Instances {
+		if instCfg.URL == inst.URL {
+			name, inst = instName, instCfg
+			break
+		}
+	}
+
+	return &Context{
+		InstanceName:   name,
+		instanceConfig: inst.withAuthentication(envTokenAuthentication()),
+		ephemeral:      true,
+	}, nil
+}
+
+func envTokenAuthentication() *Authentication {
+	return &Authentication{
+		Type:              TokenEnv,
+		EnvAuthentication: &EnvAuthentication{TokenEnv: GitlabTokenEnv},
+	}
+}
+
 func (c *Context) Instance() *InstanceConfig {
 	return c.instanceConfig
 }
 
-// GitlabClient creates a Gitlab Client from the given context
+// GitlabClient creates a Gitlab Client from the given context. Its
+// requests are checked against the protection of the context.
 func (c *Context) GitlabClient() (*gitlab.Client, error) {
-	cl, err := gogitlab.NewClient(
-		c.Instance().Authentication.Token,
-		gogitlab.WithBaseURL(c.Instance().apiURL()),
-	)
+	return c.client(c.Namespace)
+}
+
+func (c *Context) client(namespace string) (*gitlab.Client, error) {
+	if c.guard == nil {
+		// contexts that have not been resolved by the config can't ask
+		// for confirmations, see Config.ConfirmWith.
+		if c.Protection == gitlab.ConfirmWrites {
+			return nil, errors.Errorf("context %v requires confirmation for changes, but has not been resolved by the config", c.describe())
+		}
+		c.guard = &gitlab.Guard{Context: c.describe(), Protection: c.Protection}
+	}
+
+	cl, err := c.Instance().guardedClient(namespace, c.guard)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrapf(err, "could not authenticate to instance %v", c.InstanceName)
 	}
 
-	return gitlab.New(cl, c.Namespace), nil
+	return cl, nil
 }
 
-func (c *Context) Authentication() transport.AuthMethod {
-	if auth := c.Instance().Authentication.TokenAuthentication; auth != nil {
-		return &http.BasicAuth{
-			Username: "token",
-			Password: auth.Token,
-		}
+// Authentication returns the authentication for git operations.
+func (c *Context) Authentication() (transport.AuthMethod, error) {
+	auth, err := c.Instance().gitAuth()
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not authenticate to instance %v", c.InstanceName)
+	}
+	return auth, nil
+}
+
+// Repository returns the local git repository that the context has
+// been detected from, or nil if it has not been detected from one.
+func (c *Context) Repository() *LocalRepository {
+	return c.repository
+}
+
+// Project returns the project that the context refers to: the one of
+// the local git repository, or else the namespace of the context, if
+// it is a project. The project is only looked up once.
+func (c *Context) Project(ctx context.Context) (*gitlab.ProjectRef, error) {
+	if c.project != nil {
+		return c.project, nil
+	}
+
+	projectPath := c.Namespace
+	if c.repository != nil {
+		projectPath = c.repository.ProjectPath
+	}
+
+	cl, err := c.client(projectPath)
+	if err != nil {
+		return nil, err
 	}
 
-	return &http.BasicAuth{
-		Username: c.Instance().Authentication.Username,
-		Password: c.Instance().Authentication.Password,
+	project, err := cl.GetProjectRef(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get project %v", projectPath)
 	}
+
+	c.project = project
+	return project, nil
 }
 
 // WitNamespace returns a copy of the context, with the new group set
@@ -79,90 +302,229 @@ func (c *Context) WitNamespace(namespace string) *Context {
 	return &Context{
 		Namespace:      namespace,
 		InstanceName:   c.InstanceName,
+		Protection:     c.Protection,
 		instanceConfig: c.instanceConfig,
+		ephemeral:      c.ephemeral,
+		name:           c.name,
+		guard:          c.guard,
+	}
+}
+
+// withInstance returns a copy of the context that points to the given
+// instance config. It is still the same context, so a stored context
+// keeps its name and is not ephemeral.
+func (c *Context) withInstance(inst *InstanceConfig) *Context {
+	return &Context{
+		Namespace:      c.Namespace,
+		InstanceName:   c.InstanceName,
+		Protection:     c.Protection,
+		instanceConfig: inst,
+		ephemeral:      c.ephemeral,
+		name:           c.name,
+		repository:     c.repository,
 	}
 }
 
+// Name returns the name of the context in the config file. It is
+// empty for ephemeral contexts.
+func (c *Context) Name() string {
+	if c.ephemeral {
+		return ""
+	}
+	return c.name
+}
+
+// describe returns the name of the context for messages. Contexts that
+// are not stored in the config are described by their instance.
+func (c *Context) describe() string {
+	if c.name != "" {
+		return c.name
+	}
+	return fmt.Sprintf("of instance %v", c.InstanceName)
+}
+
+// IsEphemeral returns true if the context is not stored in the config
+// file, so changes to it are not persisted.
+func (c *Context) IsEphemeral() bool { return c.ephemeral }
+
 const (
 	localPath = "."
 	origin    = "origin"
 )
 
 // newGitRepoContext creates a new context out of the local git repository.
-// It checks all "origin" remote URLs and checks if there's an URL where
-// the host matches a known host from the context, and then creates a temporary
-// Context that is pointing to that instance and the right group.
-func (c *Config) newGitRepoContext(repo *git.Repository) (*Context, error) {
-	remote, err := repo.Remote(origin)
+// It checks the URLs of all remotes, starting with the preferred one, until
+// an URL's host matches a known instance. Before that, URLs are rewritten
+// by url.<base>.insteadOf of the git config and ssh host aliases are
+// resolved. The context is temporary and points to the instance and the
+// group of the project. If a remote has been selected with UseRemote, only
+// that remote is checked.
+func (c *Config) newGitRepoContext(repo *localRepository) (*Context, error) {
+	preferred := c.GitRemote
+	if c.remoteOverride != "" {
+		preferred = c.remoteOverride
+	}
+
+	gitCfg, err := repo.config()
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not generate context from git repository")
 	}
 
-	gitRemoteURL := remote.Config().URLs[0]
+	remotes := sortRemotes(gitCfg.Remotes, preferred)
+	resolver := loadRemoteResolver(gitCfg)
 
-	repoURL, err := parseGitURL(gitRemoteURL)
+	if c.remoteOverride != "" {
+		if len(remotes) == 0 || remotes[0].Name != c.remoteOverride {
+			return nil, errors.Errorf("git repository has no remote %q", c.remoteOverride)
+		}
+		remotes = remotes[:1]
+	}
+
+	branch, err := repo.head()
 	if err != nil {
-		log.Infof("instance %s is not a valid URL: %v", remote.String(), err)
+		log.Debugf("could not determine the current branch: %v", err)
 	}
 
-	log.Debugf("found repo remote: %v", repoURL)
+	for _, remote := range remotes {
+		for _, gitRemoteURL := range remote.URLs {
+			repoURL, err := resolver.resolve(gitRemoteURL)
+			if err != nil {
+				log.Debugf("ignoring URL of remote %v: %v", remote.Name, err)
+				continue
+			}
 
-	for instName, instCfg := range c.Instances {
-		if repoURL.Host == instCfg.url.Host {
-			log.Debugf("repo URL matches Instance URL %q, creating context with Group %v", repoURL.Host, repoURL.Path)
-			return &Context{
-				InstanceName:   instName,
-				Namespace:      repoURL.Path,
-				instanceConfig: instCfg,
-			}, nil
+			log.Debugf("found repo remote %v: %v", remote.Name, repoURL)
+
+			if ctx := c.instanceContext(repoURL); ctx != nil {
+				ctx.repository.Remote = remote.Name
+				ctx.repository.Branch = branch
+				ctx.repository.Upstream = upstream(gitCfg, branch)
+				return ctx, nil
+			}
 		}
 	}
 
-	return nil, errors.Errorf("no instance match found for git repository %q", repoURL)
+	return nil, errors.Errorf("no remote of the git repository matches a known instance")
 }
 
-const (
-	// these names are not really correct, but I don't know
-	// what they're actually called...TODO
-	gitSSHImplicit = "git@"
-	gitSSHExplicit = "ssh://"
+// instanceContext returns an ephemeral context for the instance that
+// serves repoURL, or nil if there is no such instance. The namespace of
+// the context is the one of the project. If several instances serve the
+// URL, their namespaces and preference decide, see matchPriority; ties
+// are broken by the instance name.
+func (c *Config) instanceContext(repoURL *url.URL) *Context {
+	names := make([]string, 0, len(c.Instances))
+	for name := range c.Instances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-	gitHTTPS = "https://"
+	var (
+		match       *Context
+		matchedPrio = -1
+	)
+	for _, instName := range names {
+		instCfg := c.Instances[instName]
+		projectPath, ok := instCfg.namespaceOf(repoURL)
+		if !ok {
+			continue
+		}
+
+		projectPath = strings.Trim(projectPath, "/")
+
+		// several instances may share the host, use the one that fits best.
+		prio := instCfg.matchPriority(projectPath)
+		if prio == matchedPrio {
+			log.Debugf("instance %v matches repo URL %v as well as instance %v, using %v",
+				instName, repoURL, match.InstanceName, match.InstanceName)
+		}
+		if prio <= matchedPrio {
+			continue
+		}
+
+		namespace := ""
+		if i := strings.LastIndex(projectPath, "/"); i != -1 {
+			namespace = projectPath[:i]
+		}
 
+		log.Debugf("repo URL matches Instance URL %q, creating context for project %v", instCfg.URL, projectPath)
+		match = &Context{
+			InstanceName:   instName,
+			Namespace:      namespace,
+			instanceConfig: instCfg,
+			ephemeral:      true,
+			repository:     &LocalRepository{ProjectPath: projectPath},
+		}
+		matchedPrio = prio
+	}
+	return match
+}
+
+const (
+	gitSSH    = "ssh"
 	gitSuffix = ".git"
 )
 
 // parseGitURL parses a git remote and returns a URL to it, if it is valid.
+// Besides URLs, the scp-like syntax "[user@]host:path" is supported. SSH
+// remotes are returned with the "ssh" scheme.
 func parseGitURL(gitURL string) (*url.URL, error) {
-	gitURL = strings.TrimPrefix(gitURL, gitSSHExplicit)
-	gitURL = strings.TrimSuffix(gitURL, gitSuffix)
-	switch {
-	case strings.HasPrefix(gitURL, gitSSHImplicit):
-		gitURL = strings.TrimPrefix(gitURL, gitSSHImplicit)
-		gitURL = strings.Replace(gitURL, ":", "/", 1)
-		gitURL = "https://" + gitURL
-
-	case strings.HasPrefix(gitURL, gitHTTPS):
-		// nothing to do
-
-	default:
+	if strings.Contains(gitURL, "://") {
+		u, err := url.Parse(gitURL)
+		if err != nil {
+			return nil, err
+		}
+
+		switch u.Scheme {
+		case gitSSH, "git+ssh", "ssh+git":
+			u.Scheme = gitSSH
+		case "https", "http", "git":
+			// nothing to do
+		default:
+			return nil, errors.Errorf("unknown git remote URL scheme: %q", gitURL)
+		}
+
+		u.Path = strings.TrimSuffix(u.Path, gitSuffix)
+		return u, nil
+	}
+
+	// the scp-like syntax requires a colon before the first slash,
+	// otherwise it is a local path.
+	colon := strings.Index(gitURL, ":")
+	if colon < 1 || strings.Contains(gitURL[:colon], "/") {
 		return nil, errors.Errorf("unknown git remote URL specification: %q", gitURL)
 	}
 
-	return url.Parse(gitURL)
+	u := &url.URL{
+		Scheme: gitSSH,
+		Host:   gitURL[:colon],
+		Path:   "/" + strings.TrimPrefix(strings.TrimSuffix(gitURL[colon+1:], gitSuffix), "/"),
+	}
+
+	if at := strings.LastIndex(u.Host, "@"); at != -1 {
+		u.User = url.User(u.Host[:at])
+		u.Host = u.Host[at+1:]
+	}
+
+	return u, nil
 }
 
 func (c *Config) getCurrentConfigContext() (*Context, error) {
-	ctx, ok := c.Contexts[c.CurrentContext]
+	return c.getConfigContext(c.CurrentContext)
+}
+
+func (c *Config) getConfigContext(name string) (*Context, error) {
+	ctx, ok := c.Contexts[name]
 	if !ok {
-		return nil, ErrInvalidContext{c.CurrentContext}
+		return nil, ErrInvalidContext{name}
 	}
 	ctx.instanceConfig, ok = c.Instances[ctx.InstanceName]
 	if !ok {
 		// TODO: custom error?
-		return nil, ErrInvalidContext{c.CurrentContext}
+		return nil, ErrInvalidContext{name}
 	}
 
-	log.Debugf("Using context %q", c.CurrentContext)
+	ctx.name = name
+	log.Debugf("Using context %q", name)
 	return ctx, nil
 }
@@ -1,6 +1,20 @@
 package config
 
-import "testing"
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/tommyknows/gitlab-cli/pkg/gitlab"
+)
 
 func TestParseGitURL(t *testing.T) {
 	tests := []struct {
@@ -14,6 +28,12 @@ func TestParseGitURL(t *testing.T) {
 		// some gitlab configurations or versions use the same format.
 		{"ssh://git@gitea.com/user/project.git", "gitea.com", "/user/project"},
 		{"https://gitlab.com/gitlab-org/gitlab.git", "gitlab.com", "/gitlab-org/gitlab"},
+		{"http://gitlab.internal:8080/gitlab/group/project.git", "gitlab.internal:8080", "/gitlab/group/project"},
+		{"ssh://git@gitlab.internal:2222/group/project.git", "gitlab.internal:2222", "/group/project"},
+		{"git+ssh://git@gitlab.com/group/project.git", "gitlab.com", "/group/project"},
+		// ssh host aliases don't need a user
+		{"gl:team/project", "gl", "/team/project"},
+		{"gitlab.com:/group/project.git", "gitlab.com", "/group/project"},
 	}
 
 	for _, tt := range tests {
@@ -29,3 +49,477 @@ func TestParseGitURL(t *testing.T) {
 		}
 	}
 }
+
+func TestParseGitURLInvalid(t *testing.T) {
+	for _, input := range []string{"/srv/git/project.git", "../project", "ftp://gitlab.com/group/project", "project"} {
+		if u, err := parseGitURL(input); err == nil {
+			t.Errorf("expected error for %q, got=%v", input, u)
+		}
+	}
+}
+
+func TestResolveRemoteURL(t *testing.T) {
+	ssh, err := parseSSHConfig(strings.NewReader(`
+# aliases for our instances
+Host gl gitlab
+    HostName gitlab.com
+    User git
+
+Host work-*
+	HostName=%h.internal
+	Port 2222
+
+Host *.corp !legacy.corp
+    Port 22
+
+Host *
+    IdentityFile ~/.ssh/id_ed25519
+`))
+	if err != nil {
+		t.Fatalf("could not parse ssh config: %v", err)
+	}
+
+	gitCfg, err := gitconfig.ReadConfig(strings.NewReader(`
+[url "git@gitlab.internal:"]
+	insteadOf = https://gitlab.internal/
+	insteadOf = internal:
+[url "git@gitlab.internal:platform/"]
+	insteadOf = internal:p/
+`))
+	if err != nil {
+		t.Fatalf("could not parse git config: %v", err)
+	}
+
+	r := newRemoteResolver([]*gitconfig.Config{gitCfg}, ssh)
+
+	tests := []struct {
+		input                  string
+		outputHost, outputPath string
+	}{
+		{"gl:team/project.git", "gitlab.com", "/team/project"},
+		{"git@gitlab:team/project.git", "gitlab.com", "/team/project"},
+		{"ssh://work-gitlab/team/project.git", "work-gitlab.internal:2222", "/team/project"},
+		// an explicit port takes precedence
+		{"ssh://git@work-gitlab:2200/team/project.git", "work-gitlab.internal:2200", "/team/project"},
+		{"git@legacy.corp:team/project.git", "legacy.corp", "/team/project"},
+		{"git@gitlab.corp:team/project.git", "gitlab.corp:22", "/team/project"},
+		// aliases only apply to ssh
+		{"https://gl/team/project.git", "gl", "/team/project"},
+		{"https://gitlab.internal/team/project.git", "gitlab.internal", "/team/project"},
+		{"internal:team/project", "gitlab.internal", "/team/project"},
+		// the longest prefix wins
+		{"internal:p/project", "gitlab.internal", "/platform/project"},
+	}
+
+	for _, tt := range tests {
+		u, err := r.resolve(tt.input)
+		if err != nil {
+			t.Errorf("error resolving %q: %v", tt.input, err)
+			continue
+		}
+
+		if u.Host != tt.outputHost || u.Path != tt.outputPath {
+			t.Errorf("resolved URL of %q not correct. expected=%v%v, got=%v%v",
+				tt.input, tt.outputHost, tt.outputPath, u.Host, u.Path)
+		}
+	}
+}
+
+func TestParseInstanceURL(t *testing.T) {
+	tests := []struct {
+		input, output string
+	}{
+		{"gitlab.com", "https://gitlab.com"},
+		{"https://gitlab.com/", "https://gitlab.com"},
+		{"http://gitlab.internal:8080/gitlab/", "http://gitlab.internal:8080/gitlab"},
+		{"gitlab.internal:8443/sub/path", "https://gitlab.internal:8443/sub/path"},
+	}
+
+	for _, tt := range tests {
+		u, err := ParseInstanceURL(tt.input)
+		if err != nil {
+			t.Errorf("error parsing instance URL %q: %v", tt.input, err)
+			continue
+		}
+
+		if u.String() != tt.output {
+			t.Errorf("parsed instance URL not correct. expected=%q, got=%q", tt.output, u.String())
+		}
+	}
+
+	if _, err := ParseInstanceURL("ftp://gitlab.com"); err == nil {
+		t.Errorf("expected error for unsupported scheme, got=%v", err)
+	}
+}
+
+func TestGitRepoContextInstanceURL(t *testing.T) {
+	inst, err := NewInstanceConfig("http://gitlab.internal:8080/gitlab", nil)
+	if err != nil {
+		t.Fatalf("could not create instance config: %v", err)
+	}
+
+	c := Default()
+	c.Instances["gitlab.internal:8080"] = inst
+
+	tests := []struct {
+		remote, namespace, project string
+	}{
+		{"http://gitlab.internal:8080/gitlab/group/project.git", "group", "group/project"},
+		{"git@gitlab.internal:group/sub/project.git", "group/sub", "group/sub/project"},
+		// the relative URL root must only be stripped from HTTP remotes
+		{"git@gitlab.internal:gitlab/project.git", "gitlab", "gitlab/project"},
+	}
+
+	dir, err := ioutil.TempDir("", "gitlab-cli-repo")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, tt := range tests {
+		path := filepath.Join(dir, strconv.Itoa(i))
+		initRepository(t, path, false, &gitconfig.RemoteConfig{Name: origin, URLs: []string{tt.remote}})
+
+		repo, err := findRepository(path)
+		if err != nil {
+			t.Fatalf("could not find repository: %v", err)
+		}
+
+		ctx, err := c.newGitRepoContext(repo)
+		if err != nil {
+			t.Errorf("could not create context for remote %q: %v", tt.remote, err)
+			continue
+		}
+
+		if ctx.Namespace != tt.namespace {
+			t.Errorf("namespace not correct for remote %q. expected=%q, got=%q", tt.remote, tt.namespace, ctx.Namespace)
+		}
+
+		if ctx.Repository().ProjectPath != tt.project {
+			t.Errorf("project not correct for remote %q. expected=%q, got=%q", tt.remote, tt.project, ctx.Repository().ProjectPath)
+		}
+	}
+}
+
+func TestGitRepoContextSameHost(t *testing.T) {
+	newInstance := func(namespaces []string, preferred bool) *InstanceConfig {
+		inst, err := NewInstanceConfig("gitlab.com", nil)
+		if err != nil {
+			t.Fatalf("could not create instance config: %v", err)
+		}
+		inst.Namespaces = namespaces
+		inst.Preferred = preferred
+		return inst
+	}
+
+	tests := []struct {
+		instances map[string]*InstanceConfig
+		remote    string
+		expected  string
+	}{
+		{
+			instances: map[string]*InstanceConfig{
+				"personal": newInstance(nil, false),
+				"work":     newInstance([]string{"company"}, false),
+			},
+			remote:   "git@gitlab.com:company/backend/api.git",
+			expected: "work",
+		},
+		{
+			instances: map[string]*InstanceConfig{
+				"personal": newInstance(nil, false),
+				"work":     newInstance([]string{"company"}, false),
+			},
+			remote:   "git@gitlab.com:tommyknows/dotfiles.git",
+			expected: "personal",
+		},
+		{
+			instances: map[string]*InstanceConfig{
+				"work":     newInstance([]string{"company"}, false),
+				"platform": newInstance([]string{"Company/Platform"}, false),
+			},
+			remote:   "https://gitlab.com/company/platform/infra.git",
+			expected: "platform",
+		},
+		{
+			instances: map[string]*InstanceConfig{
+				"a": newInstance(nil, false),
+				"b": newInstance(nil, true),
+			},
+			remote:   "git@gitlab.com:group/project.git",
+			expected: "b",
+		},
+		{
+			// without a rule, the first instance by name is used.
+			instances: map[string]*InstanceConfig{
+				"b": newInstance(nil, false),
+				"a": newInstance(nil, false),
+			},
+			remote:   "git@gitlab.com:group/project.git",
+			expected: "a",
+		},
+	}
+
+	for _, tt := range tests {
+		c := Default()
+		c.Instances = tt.instances
+
+		u, err := parseGitURL(tt.remote)
+		if err != nil {
+			t.Fatalf("could not parse remote %q: %v", tt.remote, err)
+		}
+
+		ctx := c.instanceContext(u)
+		if ctx == nil {
+			t.Errorf("no instance matches remote %q", tt.remote)
+			continue
+		}
+
+		if ctx.InstanceName != tt.expected {
+			t.Errorf("instance not correct for remote %q. expected=%v, got=%v", tt.remote, tt.expected, ctx.InstanceName)
+		}
+	}
+}
+
+func TestGitlabClientInstanceURL(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/gitlab/api/v4/namespaces/mygroup", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"id": 1, "kind": "group", "full_path": "mygroup"}`)
+	})
+	mux.HandleFunc("/gitlab/api/v4/groups/mygroup", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 1, "name": "MyGroup", "full_path": "mygroup"}`)
+	})
+	mux.HandleFunc("/gitlab/api/v4/groups/mygroup/descendant_groups", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+	mux.HandleFunc("/gitlab/api/v4/groups/mygroup/projects", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": 2, "name": "project", "path_with_namespace": "mygroup/project", "namespace": {"full_path": "mygroup"}}]`)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	inst, err := NewInstanceConfig(srv.URL+"/gitlab/", &Authentication{
+		Type:                Token,
+		TokenAuthentication: &TokenAuthentication{Token: "secret"},
+	})
+	if err != nil {
+		t.Fatalf("could not create instance config: %v", err)
+	}
+
+	ctx := &Context{
+		Namespace:      "mygroup",
+		InstanceName:   "test",
+		instanceConfig: inst,
+	}
+
+	client, err := ctx.GitlabClient()
+	if err != nil {
+		t.Fatalf("could not create gitlab client: %v", err)
+	}
+
+	root, err := client.GetProjects(context.Background(), gitlab.ProjectOptions{})
+	if err != nil {
+		t.Fatalf("could not get projects: %v", err)
+	}
+
+	if root.Name() != "MyGroup" {
+		t.Errorf("root name not correct. expected=%q, got=%q", "MyGroup", root.Name())
+	}
+}
+
+func TestGetCurrentContextPrecedence(t *testing.T) {
+	newConfig := func() *Config {
+		c := Default()
+		for _, name := range []string{"gitlab.com", "gitlab.company.com"} {
+			inst, err := NewInstanceConfig(name, &Authentication{
+				Type:                Token,
+				TokenAuthentication: &TokenAuthentication{Token: "config-token"},
+			})
+			if err != nil {
+				t.Fatalf("could not create instance config: %v", err)
+			}
+			c.Instances[name] = inst
+			c.Contexts[name] = &Context{InstanceName: name}
+		}
+		c.CurrentContext = "gitlab.com"
+		c.IgnoreLocalRepository()
+		return c
+	}
+
+	for _, env := range []string{ContextEnv, HostEnv, GitlabTokenEnv} {
+		defer os.Setenv(env, os.Getenv(env))
+		os.Unsetenv(env)
+	}
+
+	tests := []struct {
+		name            string
+		override        string
+		env             map[string]string
+		instance, token string
+		ephemeral       bool
+	}{
+		{"config", "", nil, "gitlab.com", "config-token", false},
+		{"flag", "gitlab.company.com", nil, "gitlab.company.com", "config-token", false},
+		{"env", "", map[string]string{ContextEnv: "gitlab.company.com"}, "gitlab.company.com", "config-token", false},
+		{"flag over env", "gitlab.com", map[string]string{ContextEnv: "gitlab.company.com"}, "gitlab.com", "config-token", false},
+		{"token override", "", map[string]string{GitlabTokenEnv: "env-token"}, "gitlab.com", "env-token", false},
+		{"host", "", map[string]string{HostEnv: "https://gitlab.example.com", GitlabTokenEnv: "env-token"}, "gitlab.example.com", "env-token", true},
+		{"host of configured instance", "", map[string]string{HostEnv: "gitlab.company.com", GitlabTokenEnv: "env-token"}, "gitlab.company.com", "env-token", true},
+		{"context over host", "gitlab.com", map[string]string{HostEnv: "gitlab.company.com", GitlabTokenEnv: "env-token"}, "gitlab.com", "env-token", false},
+	}
+
+	for _, tt := range tests {
+		for k, v := range tt.env {
+			os.Setenv(k, v)
+		}
+
+		c := newConfig()
+		if tt.override != "" {
+			c.OverrideContext(tt.override)
+		}
+
+		ctx, err := c.GetCurrentContext()
+		for k := range tt.env {
+			os.Unsetenv(k)
+		}
+		if err != nil {
+			t.Errorf("%v: could not get current context: %v", tt.name, err)
+			continue
+		}
+
+		if ctx.InstanceName != tt.instance {
+			t.Errorf("%v: instance not correct. expected=%q, got=%q", tt.name, tt.instance, ctx.InstanceName)
+		}
+
+		os.Setenv(GitlabTokenEnv, "env-token")
+		token, err := ctx.Instance().Authentication.ResolveToken()
+		os.Unsetenv(GitlabTokenEnv)
+		if err != nil || token != tt.token {
+			t.Errorf("%v: token not correct. expected=%q, got=%q (%v)", tt.name, tt.token, token, err)
+		}
+
+		if ctx.IsEphemeral() != tt.ephemeral {
+			t.Errorf("%v: ephemeral not correct. expected=%v, got=%v", tt.name, tt.ephemeral, ctx.IsEphemeral())
+		}
+
+		// stored contexts are named after their instance.
+		if !tt.ephemeral && ctx.Name() != tt.instance {
+			t.Errorf("%v: name not correct. expected=%q, got=%q", tt.name, tt.instance, ctx.Name())
+		}
+
+		if c.CurrentContext != "gitlab.com" {
+			t.Errorf("%v: current context of config has been changed to %q", tt.name, c.CurrentContext)
+		}
+
+		if tok := c.Instances["gitlab.com"].Authentication.TokenAuthentication.Token; tok != "config-token" {
+			t.Errorf("%v: token of config has been changed to %q", tt.name, tok)
+		}
+	}
+}
+
+func TestContextProtection(t *testing.T) {
+	inst, err := NewInstanceConfig("gitlab.company.com", nil)
+	if err != nil {
+		t.Fatalf("could not create instance config: %v", err)
+	}
+
+	c := Default()
+	c.Instances["work"] = inst
+	c.Contexts["dev"] = &Context{InstanceName: "work", Namespace: "dev"}
+	c.Contexts["prod"] = &Context{InstanceName: "work", Namespace: "prod", Protection: gitlab.ReadOnly}
+
+	repoURL, err := parseGitURL("git@gitlab.company.com:dev/project.git")
+	if err != nil {
+		t.Fatalf("could not parse git URL: %v", err)
+	}
+
+	getContext := func(name string) *Context {
+		ctx, err := c.getConfigContext(name)
+		if err != nil {
+			t.Fatalf("could not get context %v: %v", name, err)
+		}
+		return ctx
+	}
+
+	tests := []struct {
+		ctx        *Context
+		protection gitlab.Protection
+		name       string
+	}{
+		{getContext("dev"), "", "dev"},
+		{getContext("prod"), gitlab.ReadOnly, "prod"},
+		{getContext("dev").WitNamespace("dev/sub"), "", "dev"},
+		// contexts of local repositories are protected like the
+		// strictest context of their instance.
+		{c.instanceContext(repoURL), gitlab.ReadOnly, "of instance work"},
+	}
+
+	for _, tt := range tests {
+		c.protect(tt.ctx)
+
+		if tt.ctx.guard.Protection != tt.protection {
+			t.Errorf("%v: protection not correct. expected=%q, got=%q", tt.name, tt.protection, tt.ctx.guard.Protection)
+		}
+
+		if tt.ctx.guard.Context != tt.name {
+			t.Errorf("context name of guard not correct. expected=%q, got=%q", tt.name, tt.ctx.guard.Context)
+		}
+	}
+}
+
+func TestInstanceClientProtection(t *testing.T) {
+	var rotated bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/personal_access_tokens/self/rotate", func(w http.ResponseWriter, r *http.Request) {
+		rotated = true
+		fmt.Fprint(w, `{"token": "new-token"}`)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	inst, err := NewInstanceConfig(srv.URL, &Authentication{
+		Type:                Token,
+		TokenAuthentication: &TokenAuthentication{Token: "secret"},
+	})
+	if err != nil {
+		t.Fatalf("could not create instance config: %v", err)
+	}
+
+	c := Default()
+	c.Instances["work"] = inst
+	c.Contexts["dev"] = &Context{InstanceName: "work", Namespace: "dev"}
+	c.Contexts["prod"] = &Context{InstanceName: "work", Namespace: "prod", Protection: gitlab.ReadOnly}
+
+	client, err := c.InstanceClient("work", "")
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+
+	_, _, err = client.RotateToken(context.Background(), nil)
+	if !strings.Contains(fmt.Sprint(err), "is read-only") || rotated {
+		t.Errorf("token of read-only instance has been rotated. got error=%v", err)
+	}
+
+	c.Contexts["prod"].Protection = gitlab.ConfirmWrites
+	c.ConfirmWith(func(_, _ string) (bool, error) { return true, nil })
+
+	if client, err = c.InstanceClient("work", ""); err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+
+	if _, _, err := client.RotateToken(context.Background(), nil); err != nil || !rotated {
+		t.Errorf("confirmed rotation has not been sent. got error=%v", err)
+	}
+
+	// without the config, a confirmation can't be asked for.
+	unresolved := &Context{InstanceName: "work", Protection: gitlab.ConfirmWrites, instanceConfig: inst}
+	if _, err := unresolved.GitlabClient(); err == nil {
+		t.Errorf("expected error for a context that requires confirmation without a guard")
+	}
+}
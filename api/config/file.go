@@ -0,0 +1,134 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tommyknows/gitlab-cli/pkg/log"
+)
+
+const (
+	lockSuffix  = ".lock"
+	lockTimeout = 5 * time.Second
+	// locks older than this are considered to be left behind
+	// by a crashed process and are removed.
+	staleLockAge = 30 * time.Second
+)
+
+// ErrConcurrentModification is returned if the config file has been
+// modified by another process since it has been read.
+type ErrConcurrentModification struct {
+	name string
+}
+
+func (e ErrConcurrentModification) Error() string {
+	return fmt.Sprintf("config file %v has been modified by another process, not overwriting it. please retry", e.name)
+}
+
+// fileState records what has last been read from or written to the file.
+type fileState struct {
+	exists  bool
+	content []byte
+}
+
+// write writes the content to the file if it differs from what has been
+// read before.
+func (f *fileState) write(name string, content []byte) error {
+	if f.exists && bytes.Equal(f.content, content) {
+		log.Debugf("config has not changed, not writing it")
+		return nil
+	}
+
+	unlock, err := lock(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := ioutil.ReadFile(name)
+	switch {
+	case os.IsNotExist(err):
+		if f.exists {
+			return ErrConcurrentModification{name}
+		}
+	case err != nil:
+		return errors.Wrapf(err, "could not read config file")
+	case !f.exists || !bytes.Equal(f.content, current):
+		return ErrConcurrentModification{name}
+	}
+
+	if err := writeAtomic(name, content, 0600); err != nil {
+		return errors.Wrapf(err, "could not write config file")
+	}
+
+	f.exists = true
+	f.content = content
+	return nil
+}
+
+// lock creates a lock file next to the file with the given name. It
+// waits until the lock is released by other processes, for at most
+// lockTimeout.
+func lock(name string) (unlock func(), err error) {
+	lockName := name + lockSuffix
+	deadline := time.Now().Add(lockTimeout)
+
+	for {
+		f, err := os.OpenFile(lockName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockName) }, nil
+		}
+
+		if !os.IsExist(err) {
+			return nil, errors.Wrapf(err, "could not create lock file")
+		}
+
+		if stat, err := os.Stat(lockName); err == nil && time.Since(stat.ModTime()) > staleLockAge {
+			log.Debugf("removing stale lock file %v", lockName)
+			os.Remove(lockName)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, errors.Errorf("could not lock config file, remove %v if no other gitlab-cli is running", lockName)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// writeAtomic writes the content to a temporary file and renames it
+// to name, so that readers never see a partially written file.
+func writeAtomic(name string, content []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // noop after the rename
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
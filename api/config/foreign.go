@@ -0,0 +1,368 @@
+package config
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// ForeignTool is another GitLab tool, whose config can be imported.
+type ForeignTool string
+
+const (
+	Glab         ForeignTool = "glab"
+	PythonGitlab ForeignTool = "python-gitlab"
+)
+
+// ForeignTools are all tools whose config can be imported.
+var ForeignTools = []ForeignTool{Glab, PythonGitlab}
+
+// ForeignConfig holds the instances of another tool's config.
+type ForeignConfig struct {
+	// Path is the file that has been read.
+	Path      string
+	Instances map[string]*InstanceConfig
+	// Default is the name of the tool's default instance, if any.
+	Default string
+	// Unsupported maps the names of instances that cannot be
+	// imported to the reason.
+	Unsupported map[string]string
+}
+
+// ReadForeignConfig reads the config file of the tool. If path is empty,
+// the default location of the tool's config is used.
+func ReadForeignConfig(tool ForeignTool, path string) (*ForeignConfig, error) {
+	var err error
+	if path == "" {
+		if path, err = foreignConfigPath(tool); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open %v config", tool)
+	}
+	defer f.Close()
+
+	fc := &ForeignConfig{
+		Path:        path,
+		Instances:   make(map[string]*InstanceConfig),
+		Unsupported: make(map[string]string),
+	}
+
+	switch tool {
+	case Glab:
+		err = fc.readGlab(f)
+	case PythonGitlab:
+		err = fc.readPythonGitlab(f)
+	default:
+		return nil, errors.Errorf("unknown tool %q", tool)
+	}
+
+	return fc, errors.Wrapf(err, "could not parse %v config %v", tool, path)
+}
+
+// foreignConfigPath returns the default location of the tool's config.
+func foreignConfigPath(tool ForeignTool) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		configDir = filepath.Join(home, ".config")
+	}
+
+	var candidates []string
+	switch tool {
+	case Glab:
+		if dir := os.Getenv("GLAB_CONFIG_DIR"); dir != "" {
+			return filepath.Join(dir, "config.yml"), nil
+		}
+		candidates = []string{filepath.Join(configDir, "glab-cli", "config.yml")}
+
+	case PythonGitlab:
+		if path := os.Getenv("PYTHON_GITLAB_CFG"); path != "" {
+			return path, nil
+		}
+		candidates = []string{
+			filepath.Join(home, ".python-gitlab.cfg"),
+			filepath.Join(configDir, "python-gitlab", "config"),
+			"/etc/python-gitlab.cfg",
+		}
+
+	default:
+		return "", errors.Errorf("unknown tool %q", tool)
+	}
+
+	for _, c := range candidates {
+		if _, err := os.Stat(c); err == nil {
+			return c, nil
+		}
+	}
+	return candidates[0], nil
+}
+
+// glabConfig is the part of glab's config.yml that describes the hosts.
+type glabConfig struct {
+	Host  string `json:"host"`
+	Hosts map[string]struct {
+		Token         string      `json:"token"`
+		APIHost       string      `json:"api_host"`
+		APIProtocol   string      `json:"api_protocol"`
+		IsOAuth2      interface{} `json:"is_oauth2"`
+		CACert        string      `json:"ca_cert"`
+		SkipTLSVerify interface{} `json:"skip_tls_verify"`
+	} `json:"hosts"`
+}
+
+func (fc *ForeignConfig) readGlab(r io.Reader) error {
+	cont, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	var gc glabConfig
+	if err := yaml.Unmarshal(cont, &gc); err != nil {
+		return err
+	}
+
+	for host, h := range gc.Hosts {
+		switch {
+		case isTrue(h.IsOAuth2):
+			fc.Unsupported[host] = "glab's OAuth2 tokens cannot be refreshed by gitlab-cli"
+			continue
+		case h.Token == "":
+			fc.Unsupported[host] = "no token configured, it may be stored in the keyring"
+			continue
+		}
+
+		apiHost, protocol := h.APIHost, h.APIProtocol
+		if apiHost == "" {
+			apiHost = host
+		}
+		if protocol == "" {
+			protocol = "https"
+		}
+
+		inst, err := NewInstanceConfig(protocol+"://"+apiHost, &Authentication{
+			Type:                Token,
+			TokenAuthentication: &TokenAuthentication{Token: h.Token},
+		})
+		if err != nil {
+			fc.Unsupported[host] = err.Error()
+			continue
+		}
+
+		if h.CACert != "" || isTrue(h.SkipTLSVerify) {
+			inst.TLS = &TLSConfig{CAFile: h.CACert, InsecureSkipVerify: isTrue(h.SkipTLSVerify)}
+		}
+
+		fc.Instances[host] = inst
+	}
+
+	if _, ok := fc.Instances[gc.Host]; ok {
+		fc.Default = gc.Host
+	}
+	return nil
+}
+
+const (
+	pythonGitlabGlobal = "global"
+	// tokens may be read from a program instead of the config.
+	pythonGitlabHelper = "helper:"
+)
+
+func (fc *ForeignConfig) readPythonGitlab(r io.Reader) error {
+	sections, err := parseINI(r)
+	if err != nil {
+		return err
+	}
+
+	global := sections[pythonGitlabGlobal]
+
+	for name, s := range sections {
+		if name == pythonGitlabGlobal {
+			continue
+		}
+
+		if s["url"] == "" {
+			fc.Unsupported[name] = "no url configured"
+			continue
+		}
+
+		auth, reason := pythonGitlabAuthentication(s)
+		if auth == nil {
+			fc.Unsupported[name] = reason
+			continue
+		}
+
+		inst, err := NewInstanceConfig(s["url"], auth)
+		if err != nil {
+			fc.Unsupported[name] = err.Error()
+			continue
+		}
+
+		sslVerify, ok := s["ssl_verify"]
+		if !ok {
+			sslVerify, ok = global["ssl_verify"]
+		}
+
+		if ok {
+			if verify, err := strconv.ParseBool(sslVerify); err != nil {
+				// a path to a CA bundle
+				inst.TLS = &TLSConfig{CAFile: sslVerify}
+			} else if !verify {
+				inst.TLS = &TLSConfig{InsecureSkipVerify: true}
+			}
+		}
+
+		fc.Instances[name] = inst
+	}
+
+	if _, ok := fc.Instances[global["default"]]; ok {
+		fc.Default = global["default"]
+	}
+	return nil
+}
+
+// pythonGitlabAuthentication returns the authentication of a section of
+// python-gitlab's config, or the reason why it is not supported.
+func pythonGitlabAuthentication(s map[string]string) (*Authentication, string) {
+	fromHelper := func(value string) (string, bool) {
+		if !strings.HasPrefix(value, pythonGitlabHelper) {
+			return "", false
+		}
+		return strings.TrimSpace(strings.TrimPrefix(value, pythonGitlabHelper)), true
+	}
+
+	switch {
+	case s["private_token"] != "":
+		if command, ok := fromHelper(s["private_token"]); ok {
+			return &Authentication{
+				Type:                  TokenCommand,
+				CommandAuthentication: &CommandAuthentication{TokenCommand: command},
+			}, ""
+		}
+		return &Authentication{
+			Type:                Token,
+			TokenAuthentication: &TokenAuthentication{Token: s["private_token"]},
+		}, ""
+
+	case s["oauth_token"] != "":
+		if _, ok := fromHelper(s["oauth_token"]); ok {
+			return nil, "OAuth2 token helpers are not supported"
+		}
+		return &Authentication{
+			Type:                 OAuth2,
+			OAuth2Authentication: &OAuth2Authentication{AccessToken: s["oauth_token"]},
+		}, ""
+
+	case s["job_token"] != "":
+		return nil, "job tokens are only valid within a job, use the job-token authentication there"
+
+	case s["http_username"] != "" && s["http_password"] != "":
+		return &Authentication{
+			Type:                BasicAuth,
+			BasicAuthentication: &BasicAuthentication{Username: s["http_username"], Password: s["http_password"]},
+		}, ""
+	}
+
+	return nil, "no token configured"
+}
+
+// parseINI parses the sections of an INI file as written for Python's
+// configparser. Keys are lower-cased, continuation lines are not supported.
+func parseINI(r io.Reader) (map[string]map[string]string, error) {
+	sections := make(map[string]map[string]string)
+	var current map[string]string
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case line == "", strings.HasPrefix(line, "#"), strings.HasPrefix(line, ";"):
+			continue
+
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if sections[name] == nil {
+				sections[name] = make(map[string]string)
+			}
+			current = sections[name]
+			continue
+		}
+
+		i := strings.IndexAny(line, "=:")
+		if i == -1 || current == nil {
+			return nil, errors.Errorf("invalid line %q", line)
+		}
+
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		current[key] = strings.TrimSpace(line[i+1:])
+	}
+
+	return sections, s.Err()
+}
+
+// isTrue interprets a YAML value that may be a bool or a string.
+func isTrue(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
+
+// ImportForeign adds the instances of the foreign config, and a context
+// with the same name for each of them. Existing instances and contexts are
+// never replaced. If no current context is set, the tool's default instance
+// becomes the current context.
+func (c *Config) ImportForeign(fc *ForeignConfig) ImportResult {
+	var res ImportResult
+
+	names := make([]string, 0, len(fc.Instances))
+	for name := range fc.Instances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, ok := c.Instances[name]; ok {
+			res.ConflictingInstances = append(res.ConflictingInstances, name)
+			continue
+		}
+
+		inst := fc.Instances[name]
+		inst.persist = c.Write
+		c.Instances[name] = inst
+		res.ImportedInstances = append(res.ImportedInstances, name)
+
+		if _, ok := c.Contexts[name]; ok {
+			res.SkippedContexts = append(res.SkippedContexts, name)
+			continue
+		}
+
+		c.Contexts[name] = &Context{InstanceName: name}
+		res.ImportedContexts = append(res.ImportedContexts, name)
+
+		if name == fc.Default && c.CurrentContext == "" {
+			c.CurrentContext = name
+		}
+	}
+
+	return res
+}
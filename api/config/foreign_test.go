@@ -0,0 +1,176 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadForeignConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitlab-cli-foreign")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	glab := filepath.Join(dir, "config.yml")
+	writeFile(t, glab, `git_protocol: ssh
+host: gitlab.company.com
+hosts:
+  gitlab.com:
+    token: glpat-public
+    api_protocol: https
+    git_protocol: ssh
+  gitlab.company.com:
+    token: glpat-company
+    api_host: gitlab.company.com:8443
+    skip_tls_verify: true
+  gitlab.oauth.com:
+    token: oauth-token
+    is_oauth2: "true"
+  gitlab.keyring.com:
+    api_protocol: https
+`)
+
+	pythonGitlab := filepath.Join(dir, "python-gitlab.cfg")
+	writeFile(t, pythonGitlab, `[global]
+default = company
+ssl_verify = true
+timeout = 5
+
+; the company instance
+[company]
+url = http://gitlab.company.com:8080/gitlab
+private_token = helper: pass show gitlab
+ssl_verify = /etc/ssl/company.pem
+
+[public]
+url: https://gitlab.com
+oauth_token = oauth-token
+
+[basic]
+url = https://gitlab.basic.com
+http_username = user
+http_password = password
+
+[ci]
+url = https://gitlab.com
+job_token = job-token
+`)
+
+	tests := []struct {
+		tool        ForeignTool
+		path        string
+		instances   map[string]string
+		auth        map[string]AuthenticationType
+		unsupported []string
+		def         string
+	}{
+		{
+			tool:        Glab,
+			path:        glab,
+			instances:   map[string]string{"gitlab.com": "https://gitlab.com", "gitlab.company.com": "https://gitlab.company.com:8443"},
+			auth:        map[string]AuthenticationType{"gitlab.com": Token, "gitlab.company.com": Token},
+			unsupported: []string{"gitlab.keyring.com", "gitlab.oauth.com"},
+			def:         "gitlab.company.com",
+		},
+		{
+			tool:        PythonGitlab,
+			path:        pythonGitlab,
+			instances:   map[string]string{"company": "http://gitlab.company.com:8080/gitlab", "public": "https://gitlab.com", "basic": "https://gitlab.basic.com"},
+			auth:        map[string]AuthenticationType{"company": TokenCommand, "public": OAuth2, "basic": BasicAuth},
+			unsupported: []string{"ci"},
+			def:         "company",
+		},
+	}
+
+	for _, tt := range tests {
+		fc, err := ReadForeignConfig(tt.tool, tt.path)
+		if err != nil {
+			t.Errorf("%v: could not read config: %v", tt.tool, err)
+			continue
+		}
+
+		instances := make(map[string]string)
+		auth := make(map[string]AuthenticationType)
+		for name, inst := range fc.Instances {
+			instances[name] = inst.URL
+			auth[name] = inst.Authentication.Type
+		}
+
+		if !reflect.DeepEqual(instances, tt.instances) {
+			t.Errorf("%v: instances not correct. expected=%v, got=%v", tt.tool, tt.instances, instances)
+		}
+
+		if !reflect.DeepEqual(auth, tt.auth) {
+			t.Errorf("%v: authentication not correct. expected=%v, got=%v", tt.tool, tt.auth, auth)
+		}
+
+		for _, name := range tt.unsupported {
+			if _, ok := fc.Unsupported[name]; !ok {
+				t.Errorf("%v: expected instance %v to be unsupported", tt.tool, name)
+			}
+		}
+
+		if fc.Default != tt.def {
+			t.Errorf("%v: default not correct. expected=%v, got=%v", tt.tool, tt.def, fc.Default)
+		}
+	}
+
+	fc, err := ReadForeignConfig(PythonGitlab, pythonGitlab)
+	if err != nil {
+		t.Fatalf("could not read config: %v", err)
+	}
+
+	if cmd := fc.Instances["company"].Authentication.TokenCommand; cmd != "pass show gitlab" {
+		t.Errorf("token command not correct. expected=%q, got=%q", "pass show gitlab", cmd)
+	}
+
+	if tls := fc.Instances["company"].TLS; tls == nil || tls.CAFile != "/etc/ssl/company.pem" {
+		t.Errorf("CA file not imported. got=%+v", tls)
+	}
+}
+
+func TestImportForeign(t *testing.T) {
+	existing, err := NewInstanceConfig("gitlab.com", nil)
+	if err != nil {
+		t.Fatalf("could not create instance config: %v", err)
+	}
+
+	c := Default()
+	c.Instances["public"] = existing
+	c.Contexts["company"] = &Context{InstanceName: "public", Namespace: "company"}
+
+	fc := &ForeignConfig{Instances: make(map[string]*InstanceConfig), Default: "company"}
+	for name, u := range map[string]string{"public": "https://gitlab.com", "company": "gitlab.company.com", "other": "gitlab.other.com"} {
+		inst, err := NewInstanceConfig(u, &Authentication{Type: Token, TokenAuthentication: &TokenAuthentication{Token: name}})
+		if err != nil {
+			t.Fatalf("could not create instance config: %v", err)
+		}
+		fc.Instances[name] = inst
+	}
+
+	res := c.ImportForeign(fc)
+
+	expected := ImportResult{
+		ImportedInstances:    []string{"company", "other"},
+		ImportedContexts:     []string{"other"},
+		SkippedContexts:      []string{"company"},
+		ConflictingInstances: []string{"public"},
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("import result not correct. expected=%+v, got=%+v", expected, res)
+	}
+
+	if c.Instances["public"] != existing || c.Contexts["company"].Namespace != "company" {
+		t.Errorf("existing entries have been overwritten")
+	}
+
+	// the default context is only set if the current context is not set,
+	// and the context of the default instance already existed.
+	if c.CurrentContext != "" {
+		t.Errorf("current context not correct. expected=%q, got=%q", "", c.CurrentContext)
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import "github.com/pkg/errors"
+
+// MaxHistory is the number of previous contexts that are kept.
+const MaxHistory = 20
+
+// HistoryEntry is a context that has been used before, with the
+// namespace that it had at that time.
+type HistoryEntry struct {
+	Context   string `json:"context"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// SwitchContext makes name the current context. The previous
+// context is recorded in the history.
+func (c *Config) SwitchContext(name string) error {
+	if _, ok := c.Contexts[name]; !ok {
+		return errors.Errorf("no such context: %q", name)
+	}
+
+	if name == c.CurrentContext {
+		return nil
+	}
+
+	c.recordContext(c.CurrentContext)
+	c.CurrentContext = name
+	return nil
+}
+
+// SetNamespace sets the namespace of a context. The previous namespace
+// is recorded in the history.
+func (c *Config) SetNamespace(name, namespace string) error {
+	ctx, ok := c.Contexts[name]
+	if !ok {
+		return ErrInvalidContext{name}
+	}
+
+	if ctx.Namespace == namespace {
+		return nil
+	}
+
+	c.record(HistoryEntry{Context: name, Namespace: ctx.Namespace})
+	ctx.Namespace = namespace
+	return nil
+}
+
+// SwitchBack returns to the last context of the history, with the
+// namespace it had then. The current context is recorded in the history,
+// so that switching back twice returns to the current context.
+func (c *Config) SwitchBack() (HistoryEntry, error) {
+	if len(c.History) == 0 {
+		return HistoryEntry{}, errors.New("there is no previous context")
+	}
+
+	prev := c.History[0]
+	c.History = c.History[1:]
+
+	ctx, ok := c.Contexts[prev.Context]
+	if !ok {
+		return prev, errors.Errorf("the previous context %q does not exist anymore", prev.Context)
+	}
+
+	c.recordContext(c.CurrentContext)
+	ctx.Namespace = prev.Namespace
+	c.CurrentContext = prev.Context
+	return prev, nil
+}
+
+// recordContext records the context with its current namespace.
+func (c *Config) recordContext(name string) {
+	if ctx, ok := c.Contexts[name]; ok {
+		c.record(HistoryEntry{Context: name, Namespace: ctx.Namespace})
+	}
+}
+
+func (c *Config) record(entry HistoryEntry) {
+	if len(c.History) > 0 && c.History[0] == entry {
+		return
+	}
+
+	c.History = append([]HistoryEntry{entry}, c.History...)
+	if len(c.History) > MaxHistory {
+		c.History = c.History[:MaxHistory]
+	}
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	c := Default()
+	c.Contexts["a"] = &Context{InstanceName: "gitlab.com", Namespace: "group-a"}
+	c.Contexts["b"] = &Context{InstanceName: "gitlab.com", Namespace: "group-b"}
+	c.CurrentContext = "a"
+
+	if _, err := c.SwitchBack(); err == nil {
+		t.Errorf("expected error switching back without history")
+	}
+
+	if err := c.SwitchContext("nonexistent"); err == nil {
+		t.Errorf("expected error switching to nonexistent context")
+	}
+
+	if err := c.SwitchContext("b"); err != nil {
+		t.Fatalf("could not switch context: %v", err)
+	}
+
+	if err := c.SetNamespace("b", "group-b/sub"); err != nil {
+		t.Fatalf("could not set namespace: %v", err)
+	}
+
+	expected := []HistoryEntry{{"b", "group-b"}, {"a", "group-a"}}
+	if !reflect.DeepEqual(c.History, expected) {
+		t.Errorf("history not correct. expected=%v, got=%v", expected, c.History)
+	}
+
+	// toggle between the namespaces of b
+	for _, ns := range []string{"group-b", "group-b/sub", "group-b"} {
+		if _, err := c.SwitchBack(); err != nil {
+			t.Fatalf("could not switch back: %v", err)
+		}
+
+		if c.CurrentContext != "b" || c.Contexts["b"].Namespace != ns {
+			t.Errorf("context not correct after switching back. expected=b/%v, got=%v/%v", ns, c.CurrentContext, c.Contexts["b"].Namespace)
+		}
+	}
+
+	if err := c.RenameContext("a", "renamed"); err != nil {
+		t.Fatalf("could not rename context: %v", err)
+	}
+
+	if c.History[len(c.History)-1].Context != "renamed" {
+		t.Errorf("history not updated after rename. got=%v", c.History)
+	}
+
+	for i := 0; i < 2*MaxHistory; i++ {
+		if err := c.SwitchContext([]string{"b", "renamed"}[i%2]); err != nil {
+			t.Fatalf("could not switch context: %v", err)
+		}
+	}
+
+	if len(c.History) != MaxHistory {
+		t.Errorf("history not truncated. expected=%v, got=%v", MaxHistory, len(c.History))
+	}
+}
@@ -0,0 +1,368 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/tommyknows/gitlab-cli/pkg/log"
+)
+
+// ConfigEnv lists the config files to merge, separated like $PATH.
+const ConfigEnv = "GITLAB_CLI_CONFIG"
+
+// sections are the parts of the config that hold named entries, which
+// are merged entry by entry and field by field.
+var sections = map[string]bool{
+	"instances": true,
+	"contexts":  true,
+}
+
+// SplitConfigPaths splits a list of config files, as used in ConfigEnv.
+// Empty elements are ignored.
+func SplitConfigPaths(list string) []string {
+	var paths []string
+	for _, p := range strings.Split(list, string(os.PathListSeparator)) {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// layer is one of the config files that are merged.
+type layer struct {
+	name  string
+	raw   map[string]interface{}
+	file  *fileState
+	dirty bool
+}
+
+// layers are the config files that make up a config. Like the fileState
+// of a single file, they are shared between copies of the config.
+type layers struct {
+	layers []*layer
+	// merged is the config as it has been loaded / last been written,
+	// to determine what has changed.
+	merged map[string]interface{}
+}
+
+// LoadFiles loads the config files and merges them. Earlier files take
+// precedence over later ones:
+//
+//   - instances and contexts are merged field by field. For each field
+//     (e.g. the authentication of an instance), the first file that sets
+//     it wins, even if it is set to false or 0.
+//   - all other settings, like the current context, are taken from the
+//     first file that sets them.
+//
+// Files that don't exist are treated as empty. Changes are written back
+// to the file that they have been taken from: a changed field of an
+// instance or context to the file that sets it, a deleted instance or
+// context to all files that define it. New entries and fields and all
+// other settings are written to the first, personal file.
+func LoadFiles(filenames []string, useConfigContext bool) (*Config, error) {
+	if len(filenames) == 1 {
+		return Load(filenames[0], useConfigContext)
+	}
+
+	if len(filenames) == 0 {
+		return nil, errors.New("no config files given")
+	}
+
+	ls := new(layers)
+	for _, name := range filenames {
+		l, err := readLayer(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not load config file %v", name)
+		}
+		ls.layers = append(ls.layers, l)
+	}
+
+	c := Default()
+	c.useConfigContext = useConfigContext
+	merged, _ := ls.merge()
+	if err := c.unmarshalRaw(merged); err != nil {
+		return nil, err
+	}
+
+	loaded, err := toRaw(c)
+	if err != nil {
+		return nil, err
+	}
+	ls.merged = loaded
+	c.layers = ls
+
+	for _, instance := range c.Instances {
+		instance.persist = c.Write
+	}
+
+	return c, nil
+}
+
+func readLayer(name string) (*layer, error) {
+	l := &layer{
+		name: name,
+		raw:  make(map[string]interface{}),
+		file: new(fileState),
+	}
+
+	cont, err := ioutil.ReadFile(name)
+	switch {
+	case os.IsNotExist(err):
+		return l, nil
+	case err != nil:
+		return nil, err
+	}
+
+	if l.raw, err = parseRaw(cont); err != nil {
+		return nil, err
+	}
+
+	l.file.exists = true
+	l.file.content = cont
+	return l, nil
+}
+
+// merge merges the layers according to the rules of LoadFiles. A null
+// entry or field hides the entry or field of the later layers. The
+// sources are the layers that the entries ("section/name") and their
+// fields ("section/name/field") are taken from.
+func (ls *layers) merge() (merged map[string]interface{}, sources map[string]*layer) {
+	merged = make(map[string]interface{})
+	sources = make(map[string]*layer)
+	// seen records the settings, entries and fields that an earlier
+	// layer has decided on, including deletions.
+	seen := make(map[string]bool)
+
+	for _, l := range ls.layers {
+		for key, value := range l.raw {
+			if !sections[key] {
+				if !seen[key] {
+					seen[key] = true
+					if value != nil {
+						merged[key] = value
+					}
+				}
+				continue
+			}
+
+			entries, _ := value.(map[string]interface{})
+			for name, entry := range entries {
+				entryKey := key + "/" + name
+				if seen[entryKey] {
+					// deleted by an earlier layer.
+					continue
+				}
+				if entry == nil {
+					seen[entryKey] = true
+					continue
+				}
+				if sources[entryKey] == nil {
+					sources[entryKey] = l
+				}
+
+				fields, _ := entry.(map[string]interface{})
+				for field, v := range fields {
+					fieldKey := entryKey + "/" + field
+					if seen[fieldKey] {
+						continue
+					}
+					seen[fieldKey] = true
+					sources[fieldKey] = l
+					if v != nil {
+						sectionEntry(merged, key, name)[field] = v
+					}
+				}
+			}
+		}
+	}
+
+	return merged, sources
+}
+
+// write writes the changes of c since the last load / write to the
+// layers, according to the rules of LoadFiles.
+func (ls *layers) write(c *Config) error {
+	current, err := toRaw(c)
+	if err != nil {
+		return err
+	}
+
+	personal := ls.layers[0]
+	_, sources := ls.merge()
+	later, _ := (&layers{layers: ls.layers[1:]}).merge()
+
+	for key := range union(ls.merged, current) {
+		if !sections[key] {
+			if !reflect.DeepEqual(ls.merged[key], current[key]) {
+				setField(personal, personal.raw, later, key, current)
+			}
+			continue
+		}
+
+		oldEntries, _ := ls.merged[key].(map[string]interface{})
+		newEntries, _ := current[key].(map[string]interface{})
+		for name := range union(oldEntries, newEntries) {
+			oldEntry, newEntry := oldEntries[name], newEntries[name]
+			switch {
+			case newEntry == nil:
+				ls.deleteEntry(key, name)
+			case oldEntry == nil:
+				ls.addEntry(key, name, newEntry, layerEntry(later, key, name))
+			default:
+				ls.updateEntry(key, name, oldEntry, newEntry, sources)
+			}
+		}
+	}
+
+	for _, l := range ls.layers {
+		if !l.dirty {
+			continue
+		}
+
+		// without a version, the file would be migrated as a legacy file.
+		l.raw[apiVersionKey] = CurrentAPIVersion
+
+		cont, err := yaml.Marshal(l.raw)
+		if err != nil {
+			return errors.Wrapf(err, "could not marshal config")
+		}
+
+		if err := l.file.write(l.name, cont); err != nil {
+			return err
+		}
+		l.dirty = false
+	}
+
+	ls.merged = current
+	return nil
+}
+
+// deleteEntry deletes an instance or context from all layers that
+// define it.
+func (ls *layers) deleteEntry(section, name string) {
+	for _, l := range ls.layers {
+		entries, _ := l.raw[section].(map[string]interface{})
+		if _, ok := entries[name]; !ok {
+			continue
+		}
+
+		log.Debugf("deleting %v %v from config file %v", section, name, l.name)
+		delete(entries, name)
+		l.dirty = true
+	}
+}
+
+// addEntry adds an instance or context to the personal layer. If it has
+// hidden the entry of a later layer (see merge), the fields of the later
+// layer are marked as deleted, so that they don't reappear.
+func (ls *layers) addEntry(section, name string, newEntry interface{}, later map[string]interface{}) {
+	personal := ls.layers[0]
+	newFields, _ := newEntry.(map[string]interface{})
+
+	for field := range union(later, newFields) {
+		setField(personal, sectionEntry(personal.raw, section, name), later, field, newFields)
+	}
+}
+
+// updateEntry writes the changed fields of an instance or context to the
+// layers that they have been taken from. New fields are written to the
+// personal layer, removed fields are removed from all layers.
+func (ls *layers) updateEntry(section, name string, oldEntry, newEntry interface{}, sources map[string]*layer) {
+	oldFields, _ := oldEntry.(map[string]interface{})
+	newFields, _ := newEntry.(map[string]interface{})
+
+	for field := range union(oldFields, newFields) {
+		if reflect.DeepEqual(oldFields[field], newFields[field]) {
+			continue
+		}
+
+		if _, ok := newFields[field]; !ok {
+			for _, l := range ls.layers {
+				fields := layerEntry(l.raw, section, name)
+				if _, ok := fields[field]; ok {
+					log.Debugf("removing %v of %v %v from config file %v", field, section, name, l.name)
+					delete(fields, field)
+					l.dirty = true
+				}
+			}
+			continue
+		}
+
+		l := sources[section+"/"+name+"/"+field]
+		if l == nil {
+			l = ls.layers[0]
+		}
+		setField(l, sectionEntry(l.raw, section, name), nil, field, newFields)
+	}
+}
+
+// setField sets the field of raw, which is part of the layer, to its value
+// in current. If the field has been removed, it is removed from raw, or
+// marked as deleted if it would otherwise be taken from the later layers.
+func setField(l *layer, raw, later map[string]interface{}, field string, current map[string]interface{}) {
+	log.Debugf("writing change to config file %v", l.name)
+	l.dirty = true
+
+	if value, ok := current[field]; ok {
+		raw[field] = value
+		return
+	}
+
+	if _, ok := later[field]; ok {
+		raw[field] = nil
+		return
+	}
+	delete(raw, field)
+}
+
+// layerEntry returns the fields of the entry in the section, or nil.
+func layerEntry(raw map[string]interface{}, section, name string) map[string]interface{} {
+	entries, _ := raw[section].(map[string]interface{})
+	fields, _ := entries[name].(map[string]interface{})
+	return fields
+}
+
+// sectionEntry returns the fields of the entry in the section,
+// creating the section and the entry if needed.
+func sectionEntry(raw map[string]interface{}, section, name string) map[string]interface{} {
+	entries, ok := raw[section].(map[string]interface{})
+	if !ok {
+		entries = make(map[string]interface{})
+		raw[section] = entries
+	}
+
+	fields, ok := entries[name].(map[string]interface{})
+	if !ok {
+		fields = make(map[string]interface{})
+		entries[name] = fields
+	}
+	return fields
+}
+
+// toRaw converts the config to a generic map, like it is written.
+func toRaw(c *Config) (map[string]interface{}, error) {
+	j, err := json.Marshal(c)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not marshal config")
+	}
+
+	raw := make(map[string]interface{})
+	return raw, json.Unmarshal(j, &raw)
+}
+
+func union(a, b map[string]interface{}) map[string]bool {
+	keys := make(map[string]bool, len(a)+len(b))
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	return keys
+}
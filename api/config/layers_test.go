@@ -0,0 +1,282 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitlab-cli-config")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	personal := filepath.Join(dir, "personal.yml")
+	team := filepath.Join(dir, "team.yml")
+
+	writeFile(t, personal, `apiVersion: v1
+currentContext: platform
+instances:
+  work:
+    authentication:
+      type: token
+      token: secret
+`)
+	writeFile(t, team, `apiVersion: v1
+currentContext: team-default
+tokenExpiryWarningDays: 14
+instances:
+  work:
+    url: https://gitlab.company.com
+  public:
+    url: https://gitlab.com
+contexts:
+  platform:
+    instance: work
+    namespace: platform
+  infra:
+    instance: work
+    namespace: infra
+`)
+
+	c, err := LoadFiles([]string{personal, team, filepath.Join(dir, "nonexistent.yml")}, false)
+	if err != nil {
+		t.Fatalf("could not load config: %v", err)
+	}
+
+	if c.CurrentContext != "platform" || c.TokenExpiryWarningDays != 14 {
+		t.Errorf("settings not merged. got=%q/%v", c.CurrentContext, c.TokenExpiryWarningDays)
+	}
+
+	work := c.Instances["work"]
+	if work == nil || work.URL != "https://gitlab.company.com" || work.Authentication.TokenAuthentication.Token != "secret" {
+		t.Fatalf("instance not merged. got=%+v", work)
+	}
+
+	if len(c.Contexts) != 2 || len(c.Instances) != 2 {
+		t.Errorf("number of entries not correct. got=%v contexts, %v instances", len(c.Contexts), len(c.Instances))
+	}
+
+	// nothing has changed, nothing is written.
+	if err := c.Write(); err != nil {
+		t.Fatalf("could not write config: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "nonexistent.yml")); !os.IsNotExist(err) {
+		t.Errorf("unchanged config has been written")
+	}
+
+	teamBefore, err := ioutil.ReadFile(team)
+	if err != nil {
+		t.Fatalf("could not read team file: %v", err)
+	}
+
+	// these changes are owned by the personal file.
+	c.CurrentContext = "infra"
+	c.Contexts["personal"] = &Context{InstanceName: "public", Namespace: "tommyknows"}
+	c.Instances["work"].Authentication.TokenAuthentication.Token = "rotated"
+
+	if err := c.Write(); err != nil {
+		t.Fatalf("could not write config: %v", err)
+	}
+
+	teamAfter, err := ioutil.ReadFile(team)
+	if err != nil {
+		t.Fatalf("could not read team file: %v", err)
+	}
+
+	if string(teamBefore) != string(teamAfter) {
+		t.Errorf("team file has been modified:\n%s", teamAfter)
+	}
+
+	p, err := readLayer(personal)
+	if err != nil {
+		t.Fatalf("could not read personal file: %v", err)
+	}
+
+	if p.raw["currentContext"] != "infra" || layerEntry(p.raw, "contexts", "personal") == nil {
+		t.Errorf("personal file not correct. got=%v", p.raw)
+	}
+
+	if contexts := p.raw["contexts"].(map[string]interface{}); len(contexts) != 1 {
+		t.Errorf("contexts of the team file have been copied to the personal file. got=%v", contexts)
+	}
+
+	// the URL of the team file must not be copied to the personal file.
+	if work := layerEntry(p.raw, "instances", "work"); work["url"] != nil {
+		t.Errorf("instance URL has been copied to the personal file. got=%v", work)
+	}
+
+	// these entries are owned by the team file, the new authentication
+	// is written to the personal file.
+	c.Contexts["platform"].Namespace = "platform/backend"
+	c.Instances["work"].URL = "https://gitlab.company.org"
+	delete(c.Contexts, "infra")
+	c.Instances["public"].Authentication = &Authentication{
+		Type:                Token,
+		TokenAuthentication: &TokenAuthentication{Token: "public-secret"},
+	}
+
+	if err := c.Write(); err != nil {
+		t.Fatalf("could not write config: %v", err)
+	}
+
+	l, err := readLayer(team)
+	if err != nil {
+		t.Fatalf("could not read team file: %v", err)
+	}
+
+	if platform := layerEntry(l.raw, "contexts", "platform"); platform["namespace"] != "platform/backend" {
+		t.Errorf("context has not been changed in the team file. got=%v", platform)
+	}
+
+	if work := layerEntry(l.raw, "instances", "work"); work["url"] != "https://gitlab.company.org" {
+		t.Errorf("instance has not been changed in the team file. got=%v", work)
+	}
+
+	if contexts := l.raw["contexts"].(map[string]interface{}); contexts["infra"] != nil {
+		t.Errorf("context has not been deleted from the team file. got=%v", contexts)
+	}
+
+	if public := layerEntry(l.raw, "instances", "public"); public["authentication"] != nil {
+		t.Errorf("authentication has been written to the team file. got=%v", public)
+	}
+
+	if p, err = readLayer(personal); err != nil {
+		t.Fatalf("could not read personal file: %v", err)
+	}
+
+	if contexts := p.raw["contexts"].(map[string]interface{}); len(contexts) != 1 {
+		t.Errorf("contexts of the team file have been copied to the personal file. got=%v", contexts)
+	}
+
+	if work := layerEntry(p.raw, "instances", "work"); work["url"] != nil {
+		t.Errorf("instance URL has been copied to the personal file. got=%v", work)
+	}
+
+	c, err = LoadFiles([]string{personal, team}, false)
+	if err != nil {
+		t.Fatalf("could not load config: %v", err)
+	}
+
+	if c.Contexts["platform"].Namespace != "platform/backend" || c.Contexts["infra"] != nil {
+		t.Errorf("contexts not correct. got %v", c.Contexts)
+	}
+
+	if public := c.Instances["public"]; public.URL != "https://gitlab.com" || public.Authentication.TokenAuthentication.Token != "public-secret" {
+		t.Errorf("instance not correct. got=%+v", public)
+	}
+
+	if work := c.Instances["work"]; work.URL != "https://gitlab.company.org" || work.Authentication.TokenAuthentication.Token != "rotated" {
+		t.Errorf("instance not correct. got=%+v", work)
+	}
+}
+
+func TestLoadFilesDeletedEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitlab-cli-config")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	personal := filepath.Join(dir, "personal.yml")
+	team := filepath.Join(dir, "team.yml")
+
+	writeFile(t, personal, `apiVersion: v1
+contexts:
+  infra: null
+`)
+	writeFile(t, team, `apiVersion: v1
+contexts:
+  infra:
+    instance: work
+    namespace: infra
+`)
+
+	c, err := LoadFiles([]string{personal, team}, false)
+	if err != nil {
+		t.Fatalf("could not load config: %v", err)
+	}
+
+	if c.Contexts["infra"] != nil {
+		t.Fatalf("context deleted by the personal file has been loaded. got=%+v", c.Contexts["infra"])
+	}
+
+	// a context that is created again does not inherit
+	// the fields of the team file.
+	c.Contexts["infra"] = &Context{InstanceName: "public"}
+
+	if err := c.Write(); err != nil {
+		t.Fatalf("could not write config: %v", err)
+	}
+
+	if c, err = LoadFiles([]string{personal, team}, false); err != nil {
+		t.Fatalf("could not load config: %v", err)
+	}
+
+	if infra := c.Contexts["infra"]; infra == nil || infra.InstanceName != "public" || infra.Namespace != "" {
+		t.Errorf("recreated context not correct. got=%+v", infra)
+	}
+
+	l, err := readLayer(team)
+	if err != nil {
+		t.Fatalf("could not read team file: %v", err)
+	}
+
+	if infra := layerEntry(l.raw, "contexts", "infra"); infra["namespace"] != "infra" {
+		t.Errorf("team file has been modified. got=%v", l.raw)
+	}
+}
+
+func TestLoadFilesZeroValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitlab-cli-config")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	personal := filepath.Join(dir, "personal.yml")
+	team := filepath.Join(dir, "team.yml")
+
+	writeFile(t, personal, `apiVersion: v1
+tokenExpiryWarningDays: 0
+`)
+	writeFile(t, team, `apiVersion: v1
+tokenExpiryWarningDays: 14
+preferContext: true
+`)
+
+	c, err := LoadFiles([]string{personal, team}, false)
+	if err != nil {
+		t.Fatalf("could not load config: %v", err)
+	}
+
+	if c.TokenExpiryWarningDays != 0 || !c.PreferConfigContext {
+		t.Errorf("settings not merged. got=%v/%v", c.TokenExpiryWarningDays, c.PreferConfigContext)
+	}
+
+	c.PreferConfigContext = false
+	if err := c.Write(); err != nil {
+		t.Fatalf("could not write config: %v", err)
+	}
+
+	if c, err = LoadFiles([]string{personal, team}, false); err != nil {
+		t.Fatalf("could not load config: %v", err)
+	}
+
+	if c.PreferConfigContext {
+		t.Errorf("false setting has not been written to the personal file")
+	}
+
+	l, err := readLayer(team)
+	if err != nil {
+		t.Fatalf("could not read team file: %v", err)
+	}
+
+	if l.raw["preferContext"] != true {
+		t.Errorf("team file has been modified. got=%v", l.raw)
+	}
+}
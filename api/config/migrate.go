@@ -0,0 +1,66 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+	"github.com/tommyknows/gitlab-cli/pkg/log"
+)
+
+// CurrentAPIVersion is the version of the config file format
+// that is written by this version of the CLI.
+const CurrentAPIVersion = "v1"
+
+const apiVersionKey = "apiVersion"
+
+// migration migrates the raw (JSON-decoded) config from one
+// version of the file format to the next one.
+type migration struct {
+	from, to string
+	migrate  func(cfg map[string]interface{}) error
+}
+
+// migrations are applied in order, starting with the migration
+// that matches the version of the config file.
+var migrations = []migration{
+	// configs without version have been written before the URL of an
+	// instance has been stored explicitly, it was derived from the name.
+	{from: "", to: "v1", migrate: migrateInstanceURLs},
+}
+
+func migrate(cfg map[string]interface{}) error {
+	version, _ := cfg[apiVersionKey].(string)
+
+	for _, m := range migrations {
+		if m.from != version {
+			continue
+		}
+
+		log.Debugf("migrating config from version %q to %q", m.from, m.to)
+		if err := m.migrate(cfg); err != nil {
+			return errors.Wrapf(err, "could not migrate from version %q to %q", m.from, m.to)
+		}
+
+		version = m.to
+		cfg[apiVersionKey] = version
+	}
+
+	if version != CurrentAPIVersion {
+		return errors.Errorf("unknown config version %q, is the config from a newer version of gitlab-cli?", version)
+	}
+
+	return nil
+}
+
+func migrateInstanceURLs(cfg map[string]interface{}) error {
+	instances, _ := cfg["instances"].(map[string]interface{})
+	for name, i := range instances {
+		instance, ok := i.(map[string]interface{})
+		if !ok {
+			return errors.Errorf("invalid instance %v", name)
+		}
+
+		if u, _ := instance["url"].(string); u == "" {
+			instance["url"] = "https://" + name
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/tommyknows/gitlab-cli/pkg/log"
+)
+
+// PinFileName is the name of the file that pins the context of the
+// directory it is in, and of all directories below.
+const PinFileName = ".gitlab-cli"
+
+// Pin selects the context of a directory tree. Either a context is
+// pinned, or an instance with a namespace. If both a context and a
+// namespace are set, the namespace replaces the one of the context.
+type Pin struct {
+	Context   string `json:"context,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// findPin walks up from dir until it finds a pin file. If there is none,
+// nil is returned. The path of the found file is returned too.
+func findPin(dir string) (*Pin, string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for {
+		path := filepath.Join(dir, PinFileName)
+		b, err := ioutil.ReadFile(path)
+		switch {
+		case err == nil:
+			pin := new(Pin)
+			if err := yaml.Unmarshal(b, pin); err != nil {
+				return nil, path, errors.Wrapf(err, "could not parse %v", path)
+			}
+			return pin, path, nil
+
+		case !os.IsNotExist(err):
+			return nil, path, err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, "", nil
+		}
+		dir = parent
+	}
+}
+
+// WritePin writes the pin into dir. An existing pin file is not
+// overwritten, as it may have been adjusted by the user.
+func WritePin(dir string, pin *Pin) error {
+	path := filepath.Join(dir, PinFileName)
+	if _, err := os.Stat(path); err == nil {
+		log.Debugf("not overwriting existing pin file %v", path)
+		return nil
+	}
+
+	b, err := yaml.Marshal(pin)
+	if err != nil {
+		return errors.Wrapf(err, "could not marshal pin")
+	}
+
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// newPinnedContext returns the context that is selected by the pin.
+func (c *Config) newPinnedContext(pin *Pin) (*Context, error) {
+	if pin.Context != "" {
+		ctx, err := c.getConfigContext(pin.Context)
+		if err != nil || pin.Namespace == "" {
+			return ctx, err
+		}
+
+		ctx = ctx.WitNamespace(pin.Namespace)
+		ctx.ephemeral = true
+		return ctx, nil
+	}
+
+	if pin.Instance == "" {
+		return nil, errors.New("neither context nor instance are pinned")
+	}
+
+	inst, ok := c.Instances[pin.Instance]
+	if !ok {
+		return nil, errors.Errorf("pinned instance %q does not exist", pin.Instance)
+	}
+
+	return &Context{
+		Namespace:      pin.Namespace,
+		InstanceName:   pin.Instance,
+		instanceConfig: inst,
+		ephemeral:      true,
+	}, nil
+}
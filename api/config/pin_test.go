@@ -0,0 +1,127 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPinnedContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitlab-cli-pin")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("could not get working directory: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	for _, env := range []string{ContextEnv, HostEnv, GitlabTokenEnv} {
+		defer os.Setenv(env, os.Getenv(env))
+		os.Unsetenv(env)
+	}
+
+	newConfig := func() *Config {
+		c := Default()
+		inst, err := NewInstanceConfig("gitlab.com", nil)
+		if err != nil {
+			t.Fatalf("could not create instance config: %v", err)
+		}
+		c.Instances["gitlab.com"] = inst
+		c.Contexts["default"] = &Context{InstanceName: "gitlab.com", Namespace: "default"}
+		c.Contexts["work"] = &Context{InstanceName: "gitlab.com", Namespace: "company"}
+		c.CurrentContext = "default"
+		c.IgnoreLocalRepository()
+		return c
+	}
+
+	tests := []struct {
+		name      string
+		pin       string
+		namespace string
+		ephemeral bool
+		err       bool
+	}{
+		{"none", "", "default", false, false},
+		{"context", "context: work\n", "company", false, false},
+		{"context with namespace", "context: work\nnamespace: company/platform\n", "company/platform", true, false},
+		{"instance", "instance: gitlab.com\nnamespace: platform\n", "platform", true, false},
+		{"unknown instance", "instance: gitlab.example.com\n", "", false, true},
+		{"unknown context", "context: nonexistent\n", "", false, true},
+	}
+
+	for _, tt := range tests {
+		root := filepath.Join(dir, tt.name)
+		sub := filepath.Join(root, "a", "b")
+		if err := os.MkdirAll(sub, 0755); err != nil {
+			t.Fatalf("could not create directory: %v", err)
+		}
+
+		if tt.pin != "" {
+			writeFile(t, filepath.Join(root, PinFileName), tt.pin)
+		}
+
+		if err := os.Chdir(sub); err != nil {
+			t.Fatalf("could not change directory: %v", err)
+		}
+
+		ctx, err := newConfig().GetCurrentContext()
+		if tt.err {
+			if err == nil {
+				t.Errorf("%v: expected error, got context with namespace %v", tt.name, ctx.Namespace)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: could not get context: %v", tt.name, err)
+			continue
+		}
+
+		if ctx.Namespace != tt.namespace || ctx.IsEphemeral() != tt.ephemeral {
+			t.Errorf("%v: context not correct. expected=%v/%v, got=%v/%v",
+				tt.name, tt.namespace, tt.ephemeral, ctx.Namespace, ctx.IsEphemeral())
+		}
+
+		// --use-config-context ignores pins
+		c := newConfig()
+		c.useConfigContext = true
+		if ctx, err := c.GetCurrentContext(); err != nil || ctx.Namespace != "default" {
+			t.Errorf("%v: pin not ignored with config context. got=%v, %v", tt.name, ctx, err)
+		}
+	}
+}
+
+func TestWritePin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitlab-cli-pin")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := WritePin(dir, &Pin{Instance: "gitlab.com", Namespace: "platform"}); err != nil {
+		t.Fatalf("could not write pin: %v", err)
+	}
+
+	// existing pins are kept
+	if err := WritePin(dir, &Pin{Context: "other"}); err != nil {
+		t.Fatalf("could not write pin: %v", err)
+	}
+
+	pin, path, err := findPin(filepath.Join(dir, "a", "b"))
+	if err != nil {
+		t.Fatalf("could not find pin: %v", err)
+	}
+
+	if path != filepath.Join(dir, PinFileName) {
+		t.Errorf("path not correct. expected=%v, got=%v", filepath.Join(dir, PinFileName), path)
+	}
+
+	if *pin != (Pin{Instance: "gitlab.com", Namespace: "platform"}) {
+		t.Errorf("pin not correct. got=%+v", *pin)
+	}
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/pkg/errors"
+	"github.com/tommyknows/gitlab-cli/pkg/log"
+)
+
+// remoteResolver resolves git remote URLs the way git and ssh would,
+// so that they can be matched against the instances.
+type remoteResolver struct {
+	// rewrites maps the prefixes of url.<base>.insteadOf to their base.
+	rewrites map[string]string
+	ssh      *sshConfig
+}
+
+// loadRemoteResolver creates a resolver from the given repository config,
+// the global and system git configs and the ssh config of the user.
+// Configs that cannot be read are ignored.
+func loadRemoteResolver(local *gitconfig.Config) *remoteResolver {
+	configs := []*gitconfig.Config{local}
+	for _, scope := range []gitconfig.Scope{gitconfig.GlobalScope, gitconfig.SystemScope} {
+		cfg, err := gitconfig.LoadConfig(scope)
+		if err != nil {
+			log.Debugf("could not read git config: %v", err)
+			continue
+		}
+		configs = append(configs, cfg)
+	}
+
+	ssh, err := loadSSHConfig()
+	if err != nil {
+		log.Debugf("could not read ssh config: %v", err)
+	}
+
+	return newRemoteResolver(configs, ssh)
+}
+
+func newRemoteResolver(configs []*gitconfig.Config, ssh *sshConfig) *remoteResolver {
+	r := &remoteResolver{
+		rewrites: make(map[string]string),
+		ssh:      ssh,
+	}
+
+	for _, cfg := range configs {
+		for _, ss := range cfg.Raw.Section("url").Subsections {
+			for _, prefix := range ss.Options.GetAll("insteadOf") {
+				// the first config, the one of the repository, has precedence
+				if _, ok := r.rewrites[prefix]; !ok {
+					r.rewrites[prefix] = ss.Name
+				}
+			}
+		}
+	}
+
+	return r
+}
+
+// resolve rewrites the remote URL according to url.<base>.insteadOf,
+// parses it and replaces ssh host aliases with their real host.
+func (r *remoteResolver) resolve(remoteURL string) (*url.URL, error) {
+	u, err := parseGitURL(r.rewrite(remoteURL))
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme == gitSSH && r.ssh != nil {
+		host, port := r.ssh.resolve(u.Hostname())
+		if u.Port() != "" {
+			port = u.Port()
+		}
+
+		u.Host = host
+		if port != "" {
+			u.Host = net.JoinHostPort(host, port)
+		}
+	}
+
+	return u, nil
+}
+
+// rewrite replaces the longest matching insteadOf prefix, like git does.
+func (r *remoteResolver) rewrite(remoteURL string) string {
+	var longest string
+	for prefix := range r.rewrites {
+		if strings.HasPrefix(remoteURL, prefix) && len(prefix) > len(longest) {
+			longest = prefix
+		}
+	}
+
+	if longest == "" {
+		return remoteURL
+	}
+
+	rewritten := r.rewrites[longest] + strings.TrimPrefix(remoteURL, longest)
+	log.Debugf("rewrote remote URL %v to %v", remoteURL, rewritten)
+	return rewritten
+}
+
+// sshConfig holds the parts of an ssh config that are needed to
+// resolve host aliases.
+type sshConfig struct {
+	hosts []sshHost
+}
+
+// sshHost is a "Host" block of an ssh config.
+type sshHost struct {
+	patterns []string
+	options  map[string]string
+}
+
+func loadSSHConfig() (*sshConfig, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(filepath.Join(home, ".ssh", "config"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseSSHConfig(f)
+}
+
+// parseSSHConfig parses the Host blocks of an ssh config. Match blocks
+// and Include directives are not supported and are ignored.
+func parseSSHConfig(r io.Reader) (*sshConfig, error) {
+	// options before the first Host block apply to all hosts
+	cfg := &sshConfig{
+		hosts: []sshHost{{patterns: []string{"*"}, options: make(map[string]string)}},
+	}
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value := splitSSHOption(line)
+		switch key {
+		case "host":
+			cfg.hosts = append(cfg.hosts, sshHost{patterns: strings.Fields(value), options: make(map[string]string)})
+		case "match":
+			cfg.hosts = append(cfg.hosts, sshHost{options: make(map[string]string)})
+		default:
+			// like ssh, the first obtained value is used
+			opts := cfg.hosts[len(cfg.hosts)-1].options
+			if _, ok := opts[key]; !ok {
+				opts[key] = value
+			}
+		}
+	}
+
+	return cfg, errors.Wrapf(s.Err(), "could not parse ssh config")
+}
+
+// splitSSHOption splits a line of the form "Key value" or "Key=value".
+func splitSSHOption(line string) (string, string) {
+	i := strings.IndexAny(line, " \t=")
+	if i == -1 {
+		return strings.ToLower(line), ""
+	}
+
+	value := strings.TrimSpace(line[i+1:])
+	value = strings.TrimSpace(strings.TrimPrefix(value, "="))
+	return strings.ToLower(line[:i]), strings.Trim(value, `"`)
+}
+
+// resolve returns the real hostname and the port of the host alias.
+// The port is empty if it is not configured.
+func (c *sshConfig) resolve(alias string) (string, string) {
+	host := alias
+	if hostname := c.get(alias, "hostname"); hostname != "" {
+		host = strings.Replace(hostname, "%h", alias, -1)
+		log.Debugf("resolved ssh host %v to %v", alias, host)
+	}
+	return host, c.get(alias, "port")
+}
+
+// get returns the first value of the option for host.
+func (c *sshConfig) get(host, key string) string {
+	for _, h := range c.hosts {
+		if v, ok := h.options[key]; ok && h.matches(host) {
+			return v
+		}
+	}
+	return ""
+}
+
+// matches returns true if host matches one of the patterns of the
+// block, and none of its negated patterns.
+func (h sshHost) matches(host string) bool {
+	var match bool
+	for _, p := range h.patterns {
+		negated := strings.HasPrefix(p, "!")
+		ok, _ := path.Match(strings.ToLower(strings.TrimPrefix(p, "!")), strings.ToLower(host))
+		if ok && negated {
+			return false
+		}
+		match = match || ok
+	}
+	return match
+}
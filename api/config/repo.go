@@ -0,0 +1,194 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/pkg/errors"
+)
+
+// errNoRepository is returned if no git repository could be found.
+var errNoRepository = errors.New("not in a git repository")
+
+const (
+	dotGit       = ".git"
+	gitDirPrefix = "gitdir:"
+	commonDir    = "commondir"
+	gitConfig    = "config"
+	gitHead      = "HEAD"
+	symrefPrefix = "ref:"
+)
+
+// LocalRepository describes the (filesystem-)local git repository
+// that a context has been detected from.
+type LocalRepository struct {
+	// Remote is the name of the remote that matched the instance.
+	Remote string
+	// ProjectPath is the full path of the project on the instance,
+	// e.g. "group/subgroup/project".
+	ProjectPath string
+	// Branch is the checked out branch. It is empty if HEAD is detached.
+	Branch string
+	// Upstream is the branch that Branch tracks, e.g. "origin/main".
+	// It is empty if there is none.
+	Upstream string
+}
+
+// localRepository is a git repository on the local filesystem.
+type localRepository struct {
+	// gitDir is the git directory of the working tree, which
+	// contains its HEAD.
+	gitDir string
+	// commonDir is the git directory that is shared between all working
+	// trees of the repository, which contains the config. It is the same
+	// as gitDir, unless the working tree has been added with
+	// `git worktree add`.
+	commonDir string
+}
+
+// findRepository walks up from dir until it finds a git repository.
+// Besides normal repositories, it supports working trees and submodules,
+// where ".git" is a file pointing to the actual git directory.
+func findRepository(dir string) (*localRepository, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		path := filepath.Join(dir, dotGit)
+		fi, err := os.Stat(path)
+		switch {
+		case err == nil && fi.IsDir():
+			return &localRepository{gitDir: path, commonDir: path}, nil
+
+		case err == nil:
+			return openGitFile(path)
+
+		case !os.IsNotExist(err):
+			return nil, err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, errNoRepository
+		}
+		dir = parent
+	}
+}
+
+// openGitFile reads a ".git" file of a working tree or submodule,
+// which has the format "gitdir: <path>".
+func openGitFile(path string) (*localRepository, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	line := strings.SplitN(string(b), "\n", 2)[0]
+	if !strings.HasPrefix(line, gitDirPrefix) {
+		return nil, errors.Errorf("invalid git file %v", path)
+	}
+
+	gitDir := strings.TrimSpace(strings.TrimPrefix(line, gitDirPrefix))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+
+	repo := &localRepository{gitDir: gitDir, commonDir: gitDir}
+
+	// working trees share the config of the main repository
+	b, err = ioutil.ReadFile(filepath.Join(gitDir, commonDir))
+	switch {
+	case err == nil:
+		repo.commonDir = strings.TrimSpace(string(b))
+		if !filepath.IsAbs(repo.commonDir) {
+			repo.commonDir = filepath.Join(gitDir, repo.commonDir)
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+// config reads the git config of the repository.
+func (r *localRepository) config() (*gitconfig.Config, error) {
+	f, err := os.Open(filepath.Join(r.commonDir, gitConfig))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read git config")
+	}
+	defer f.Close()
+
+	return gitconfig.ReadConfig(f)
+}
+
+// head returns the branch that is checked out in the working tree.
+// If HEAD is detached, an empty string is returned.
+func (r *localRepository) head() (string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(r.gitDir, gitHead))
+	if err != nil {
+		return "", errors.Wrapf(err, "could not read HEAD")
+	}
+
+	head := strings.TrimSpace(string(b))
+	if !strings.HasPrefix(head, symrefPrefix) {
+		return "", nil
+	}
+
+	ref := plumbing.ReferenceName(strings.TrimSpace(strings.TrimPrefix(head, symrefPrefix)))
+	if !ref.IsBranch() {
+		return "", nil
+	}
+	return ref.Short(), nil
+}
+
+// upstream returns the branch that branch tracks according to the
+// git config, in the form "<remote>/<branch>".
+func upstream(cfg *gitconfig.Config, branch string) string {
+	b, ok := cfg.Branches[branch]
+	if !ok || b.Merge == "" {
+		return ""
+	}
+
+	// "." tracks a branch of the local repository
+	if b.Remote == "" || b.Remote == "." {
+		return b.Merge.Short()
+	}
+	return b.Remote + "/" + b.Merge.Short()
+}
+
+// sortRemotes returns the remotes sorted by preference. The preferred
+// remote comes first, the others are sorted by name, with "origin" first.
+func sortRemotes(remotes map[string]*gitconfig.RemoteConfig, preferred string) []*gitconfig.RemoteConfig {
+	rank := func(name string) int {
+		switch name {
+		case preferred:
+			return 0
+		case origin:
+			return 1
+		default:
+			return 2
+		}
+	}
+
+	sorted := make([]*gitconfig.RemoteConfig, 0, len(remotes))
+	for _, remote := range remotes {
+		sorted = append(sorted, remote)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		ri, rj := rank(sorted[i].Name), rank(sorted[j].Name)
+		if ri != rj {
+			return ri < rj
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	return sorted
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+)
+
+func initRepository(t *testing.T, path string, bare bool, remotes ...*gitconfig.RemoteConfig) {
+	t.Helper()
+
+	repo, err := git.PlainInit(path, bare)
+	if err != nil {
+		t.Fatalf("could not init repository: %v", err)
+	}
+
+	for _, remote := range remotes {
+		if _, err := repo.CreateRemote(remote); err != nil {
+			t.Fatalf("could not create remote: %v", err)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("could not create directory: %v", err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+}
+
+func TestFindRepository(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitlab-cli-repo")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	main := filepath.Join(dir, "main")
+	mainGitDir := filepath.Join(main, dotGit)
+	initRepository(t, main, false)
+
+	// a working tree created with `git worktree add`
+	worktreeGitDir := filepath.Join(mainGitDir, "worktrees", "feature")
+	writeFile(t, filepath.Join(worktreeGitDir, commonDir), "../..\n")
+	writeFile(t, filepath.Join(dir, "feature", dotGit), "gitdir: "+worktreeGitDir+"\n")
+
+	// a submodule, with a relative git dir
+	submoduleGitDir := filepath.Join(mainGitDir, "modules", "lib")
+	initRepository(t, submoduleGitDir, true)
+	writeFile(t, filepath.Join(main, "lib", dotGit), "gitdir: ../.git/modules/lib\n")
+
+	if err := os.MkdirAll(filepath.Join(main, "lib", "pkg", "sub"), 0755); err != nil {
+		t.Fatalf("could not create directory: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(main, "a", "b"), 0755); err != nil {
+		t.Fatalf("could not create directory: %v", err)
+	}
+
+	tests := []struct {
+		dir                  string
+		gitDir, gitCommonDir string
+	}{
+		{main, mainGitDir, mainGitDir},
+		{filepath.Join(main, "a", "b"), mainGitDir, mainGitDir},
+		{filepath.Join(dir, "feature"), worktreeGitDir, mainGitDir},
+		{filepath.Join(main, "lib", "pkg", "sub"), submoduleGitDir, submoduleGitDir},
+	}
+
+	for _, tt := range tests {
+		repo, err := findRepository(tt.dir)
+		if err != nil {
+			t.Errorf("could not find repository from %v: %v", tt.dir, err)
+			continue
+		}
+
+		if repo.gitDir != tt.gitDir || repo.commonDir != tt.gitCommonDir {
+			t.Errorf("repository of %v not correct. expected=%v/%v, got=%v/%v",
+				tt.dir, tt.gitDir, tt.gitCommonDir, repo.gitDir, repo.commonDir)
+		}
+	}
+
+	// the root of the temp dir is most likely not within a repository.
+	if _, err := findRepository(filepath.Dir(dir)); err != nil && err != errNoRepository {
+		t.Errorf("unexpected error outside of repository: %v", err)
+	}
+}
+
+func TestGitRepoContextRemotes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitlab-cli-repo")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	initRepository(t, dir, false,
+		&gitconfig.RemoteConfig{Name: origin, URLs: []string{"git@github.com:tommyknows/project.git", "git@gitlab.com:fork/project.git"}},
+		&gitconfig.RemoteConfig{Name: "upstream", URLs: []string{"git@gitlab.com:upstream/project.git"}},
+		&gitconfig.RemoteConfig{Name: "mirror", URLs: []string{"git@github.com:mirror/project.git"}},
+	)
+
+	repo, err := findRepository(dir)
+	if err != nil {
+		t.Fatalf("could not find repository: %v", err)
+	}
+
+	inst, err := NewInstanceConfig("gitlab.com", nil)
+	if err != nil {
+		t.Fatalf("could not create instance config: %v", err)
+	}
+
+	tests := []struct {
+		preferred, override string
+		project             string
+		err                 bool
+	}{
+		// the second URL of origin matches
+		{"", "", "fork/project", false},
+		{"upstream", "", "upstream/project", false},
+		// a preferred remote that does not exist is ignored
+		{"nonexistent", "", "fork/project", false},
+		{"", "upstream", "upstream/project", false},
+		// with an override, other remotes are ignored
+		{"", "mirror", "", true},
+		{"", "nonexistent", "", true},
+	}
+
+	for _, tt := range tests {
+		c := Default()
+		c.Instances["gitlab.com"] = inst
+		c.GitRemote = tt.preferred
+		c.UseRemote(tt.override)
+
+		ctx, err := c.newGitRepoContext(repo)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q/%q: expected error, got context for project %v", tt.preferred, tt.override, ctx.Repository().ProjectPath)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q/%q: could not create context: %v", tt.preferred, tt.override, err)
+			continue
+		}
+
+		if ctx.Repository().ProjectPath != tt.project {
+			t.Errorf("%q/%q: project not correct. expected=%v, got=%v", tt.preferred, tt.override, tt.project, ctx.Repository().ProjectPath)
+		}
+	}
+}
+
+func TestGitRepoContextBranch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitlab-cli-repo")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("could not init repository: %v", err)
+	}
+
+	if _, err := repo.CreateRemote(&gitconfig.RemoteConfig{Name: origin, URLs: []string{"git@gitlab.com:group/project.git"}}); err != nil {
+		t.Fatalf("could not create remote: %v", err)
+	}
+
+	if err := repo.CreateBranch(&gitconfig.Branch{Name: "feature", Remote: origin, Merge: "refs/heads/feature/x"}); err != nil {
+		t.Fatalf("could not create branch: %v", err)
+	}
+
+	inst, err := NewInstanceConfig("gitlab.com", nil)
+	if err != nil {
+		t.Fatalf("could not create instance config: %v", err)
+	}
+
+	c := Default()
+	c.Instances["gitlab.com"] = inst
+
+	tests := []struct {
+		head             string
+		branch, upstream string
+	}{
+		{"ref: refs/heads/feature\n", "feature", "origin/feature/x"},
+		{"ref: refs/heads/master\n", "master", ""},
+		// detached HEAD
+		{"0123456789abcdef0123456789abcdef01234567\n", "", ""},
+	}
+
+	for _, tt := range tests {
+		writeFile(t, filepath.Join(dir, dotGit, gitHead), tt.head)
+
+		local, err := findRepository(dir)
+		if err != nil {
+			t.Fatalf("could not find repository: %v", err)
+		}
+
+		ctx, err := c.newGitRepoContext(local)
+		if err != nil {
+			t.Fatalf("could not create context: %v", err)
+		}
+
+		expected := LocalRepository{Remote: origin, ProjectPath: "group/project", Branch: tt.branch, Upstream: tt.upstream}
+		if *ctx.Repository() != expected {
+			t.Errorf("repository not correct. expected=%+v, got=%+v", expected, *ctx.Repository())
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"sort"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+const masked = "*****"
+
+// CurrentContextName returns the name of the context that has been selected
+// for this invocation (see OverrideContext), or the current context of the
+// config file. It does not consider the (filesystem-)local git repository.
+func (c *Config) CurrentContextName() string {
+	if name := c.selectedContext(); name != "" {
+		return name
+	}
+	return c.CurrentContext
+}
+
+// RenameContext renames a context, updating the current context if needed.
+func (c *Config) RenameContext(oldName, newName string) error {
+	ctx, ok := c.Contexts[oldName]
+	if !ok {
+		return ErrInvalidContext{oldName}
+	}
+
+	if _, ok := c.Contexts[newName]; ok {
+		return errors.Errorf("context %q already exists", newName)
+	}
+
+	c.Contexts[newName] = ctx
+	delete(c.Contexts, oldName)
+
+	if c.CurrentContext == oldName {
+		c.CurrentContext = newName
+	}
+
+	for i := range c.History {
+		if c.History[i].Context == oldName {
+			c.History[i].Context = newName
+		}
+	}
+	return nil
+}
+
+// Masked returns a copy of the instance config where all secrets
+// are replaced, so that it can be displayed.
+func (ic *InstanceConfig) Masked() *InstanceConfig {
+	i := *ic
+	if ic.Authentication == nil {
+		return &i
+	}
+
+	a := *ic.Authentication
+	a.token = ""
+
+	if a.TokenAuthentication != nil {
+		a.TokenAuthentication = &TokenAuthentication{Token: masked}
+	}
+
+	if a.BasicAuthentication != nil {
+		b := *a.BasicAuthentication
+		if b.Password != "" {
+			b.Password = masked
+		}
+		a.BasicAuthentication = &b
+	}
+
+	if a.OAuth2Authentication != nil {
+		o := *a.OAuth2Authentication
+		o.AccessToken = masked
+		if o.RefreshToken != "" {
+			o.RefreshToken = masked
+		}
+		if o.ClientSecret != "" {
+			o.ClientSecret = masked
+		}
+		a.OAuth2Authentication = &o
+	}
+
+	i.Authentication = &a
+	return &i
+}
+
+// Export returns a config with the given contexts (or all, if none are
+// given) and their instances, without any authentication. It can be
+// shared with others, who add it to their config with Import.
+func (c *Config) Export(names ...string) (*Config, error) {
+	if len(names) == 0 {
+		for name := range c.Contexts {
+			names = append(names, name)
+		}
+	}
+
+	e := Default()
+	for _, name := range names {
+		ctx, ok := c.Contexts[name]
+		if !ok {
+			return nil, ErrInvalidContext{name}
+		}
+
+		inst, ok := c.Instances[ctx.InstanceName]
+		if !ok {
+			return nil, errors.Errorf("context %q references instance %q, which does not exist", name, ctx.InstanceName)
+		}
+
+		e.Contexts[name] = &Context{
+			Namespace:    ctx.Namespace,
+			InstanceName: ctx.InstanceName,
+			Protection:   ctx.Protection,
+		}
+		e.Instances[ctx.InstanceName] = inst.withAuthentication(nil)
+	}
+
+	return e, nil
+}
+
+// Parse parses the content of an (exported) config file.
+func Parse(cont []byte) (*Config, error) {
+	c := Default()
+	if err := c.unmarshal(cont); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Marshal marshals the config to YAML, as it would be written to the file.
+func (c *Config) Marshal() ([]byte, error) {
+	return yaml.Marshal(c)
+}
+
+// ImportResult describes what has been imported.
+type ImportResult struct {
+	// ImportedContexts and ImportedInstances have been added.
+	ImportedContexts, ImportedInstances []string
+	// SkippedContexts already existed and have not been overwritten.
+	SkippedContexts []string
+	// ConflictingInstances exist already, for Import with a different
+	// URL. The existing instances are kept.
+	ConflictingInstances []string
+}
+
+// Import adds the contexts and instances of other to the config. Existing
+// contexts are only replaced if overwrite is set. Existing instances are
+// never replaced, as they hold the authentication. New instances have no
+// authentication and need to be logged in to.
+func (c *Config) Import(other *Config, overwrite bool) ImportResult {
+	var res ImportResult
+
+	for name, inst := range other.Instances {
+		existing, ok := c.Instances[name]
+		switch {
+		case !ok:
+			c.Instances[name] = inst.withAuthentication(nil)
+			res.ImportedInstances = append(res.ImportedInstances, name)
+		case existing.URL != inst.URL:
+			res.ConflictingInstances = append(res.ConflictingInstances, name)
+		}
+	}
+
+	for name, ctx := range other.Contexts {
+		if _, ok := c.Contexts[name]; ok && !overwrite {
+			res.SkippedContexts = append(res.SkippedContexts, name)
+			continue
+		}
+
+		c.Contexts[name] = &Context{
+			Namespace:    ctx.Namespace,
+			InstanceName: ctx.InstanceName,
+			Protection:   ctx.Protection,
+		}
+		res.ImportedContexts = append(res.ImportedContexts, name)
+	}
+
+	for _, s := range [][]string{res.ImportedContexts, res.ImportedInstances, res.SkippedContexts, res.ConflictingInstances} {
+		sort.Strings(s)
+	}
+
+	return res
+}
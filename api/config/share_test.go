@@ -0,0 +1,114 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tommyknows/gitlab-cli/pkg/gitlab"
+)
+
+func newShareTestConfig(t *testing.T) *Config {
+	c := Default()
+
+	inst, err := NewInstanceConfig("gitlab.com", &Authentication{
+		Type:                Token,
+		TokenAuthentication: &TokenAuthentication{Token: "secret-token"},
+	})
+	if err != nil {
+		t.Fatalf("could not create instance config: %v", err)
+	}
+
+	c.Instances["gitlab.com"] = inst
+	c.Contexts["team"] = &Context{InstanceName: "gitlab.com", Namespace: "team"}
+	c.Contexts["personal"] = &Context{InstanceName: "gitlab.com", Namespace: "me"}
+	c.CurrentContext = "team"
+	return c
+}
+
+func TestRenameContext(t *testing.T) {
+	c := newShareTestConfig(t)
+
+	if err := c.RenameContext("team", "platform"); err != nil {
+		t.Fatalf("could not rename context: %v", err)
+	}
+
+	if _, ok := c.Contexts["team"]; ok {
+		t.Errorf("old context still exists")
+	}
+
+	if c.CurrentContext != "platform" {
+		t.Errorf("current context not updated. expected=%q, got=%q", "platform", c.CurrentContext)
+	}
+
+	if err := c.RenameContext("platform", "personal"); err == nil {
+		t.Errorf("expected error when renaming to an existing context, got=%v", err)
+	}
+}
+
+func TestMasked(t *testing.T) {
+	c := newShareTestConfig(t)
+
+	m := c.Instances["gitlab.com"].Masked()
+	if m.Authentication.TokenAuthentication.Token == "secret-token" {
+		t.Errorf("token is not masked")
+	}
+
+	if c.Instances["gitlab.com"].Authentication.TokenAuthentication.Token != "secret-token" {
+		t.Errorf("masking modified the original token")
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	c := newShareTestConfig(t)
+	c.Contexts["team"].Protection = gitlab.ReadOnly
+
+	exported, err := c.Export("team")
+	if err != nil {
+		t.Fatalf("could not export contexts: %v", err)
+	}
+
+	cont, err := exported.Marshal()
+	if err != nil {
+		t.Fatalf("could not marshal exported contexts: %v", err)
+	}
+
+	if strings.Contains(string(cont), "secret-token") {
+		t.Errorf("exported contexts contain the token:\n%s", cont)
+	}
+
+	other, err := Parse(cont)
+	if err != nil {
+		t.Fatalf("could not parse exported contexts: %v", err)
+	}
+
+	if len(other.Contexts) != 1 || other.Contexts["team"] == nil {
+		t.Fatalf("exported contexts not correct. got=%v", other.Contexts)
+	}
+
+	// importing into the same config must keep the existing instance
+	// (including the token) and skip the existing context.
+	c.Contexts["team"].Namespace = "changed"
+	res := c.Import(other, false)
+	if len(res.SkippedContexts) != 1 || c.Contexts["team"].Namespace != "changed" {
+		t.Errorf("existing context has been overwritten. result=%+v", res)
+	}
+
+	if c.Instances["gitlab.com"].Authentication == nil {
+		t.Errorf("authentication of existing instance has been removed")
+	}
+
+	res = c.Import(other, true)
+	if len(res.ImportedContexts) != 1 || c.Contexts["team"].Namespace != "team" {
+		t.Errorf("existing context has not been overwritten. result=%+v", res)
+	}
+
+	empty := Default()
+	res = empty.Import(other, false)
+	if len(res.ImportedInstances) != 1 || empty.Instances["gitlab.com"].URL != "https://gitlab.com" {
+		t.Errorf("instance has not been imported. result=%+v", res)
+	}
+
+	if p := empty.Contexts["team"].Protection; p != gitlab.ReadOnly {
+		t.Errorf("protection of imported context not correct. expected=%v, got=%v", gitlab.ReadOnly, p)
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/pkg/errors"
+	"github.com/tommyknows/gitlab-cli/pkg/log"
+)
+
+// TLSConfig specifies how to establish TLS connections to an instance.
+type TLSConfig struct {
+	// CAFile is a PEM encoded CA bundle that is used to verify the
+	// server's certificate, in addition to the system's CAs.
+	CAFile string `json:"caFile,omitempty"`
+
+	// CertFile and KeyFile are a PEM encoded client certificate and
+	// key that are presented to the server (mTLS).
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+
+	// InsecureSkipVerify disables the verification of the server's
+	// certificate. Don't use this unless you know what you are doing.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+func (t *TLSConfig) config() (*tls.Config, error) {
+	cfg := &tls.Config{
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		pem, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read CA file")
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in CA file %v", t.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not load client certificate")
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// HTTPClient returns a HTTP client that is configured with the
+// TLS settings of the instance.
+func (ic *InstanceConfig) HTTPClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if ic.TLS != nil {
+		if ic.TLS.InsecureSkipVerify {
+			log.Warningf("TLS certificate verification is DISABLED for instance %v, connections are NOT secure!", ic.URL)
+		}
+
+		cfg, err := ic.TLS.config()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid TLS configuration for instance %v", ic.URL)
+		}
+		transport.TLSClientConfig = cfg
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// InstallGitTransport configures the HTTP(S) transport of git
+// operations (clone, pull) to use the TLS settings of the instance.
+// As go-git keeps the transports globally, this affects all git
+// operations of the process.
+func (ic *InstanceConfig) InstallGitTransport() error {
+	httpClient, err := ic.HTTPClient()
+	if err != nil {
+		return err
+	}
+
+	transport := githttp.NewClient(httpClient)
+	client.InstallProtocol("https", transport)
+	client.InstallProtocol("http", transport)
+	return nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestInstanceHTTPClientTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	caFile, err := ioutil.TempFile("", "gitlab-cli-ca")
+	if err != nil {
+		t.Fatalf("could not create CA file: %v", err)
+	}
+	defer os.Remove(caFile.Name())
+
+	if err := pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}); err != nil {
+		t.Fatalf("could not write CA file: %v", err)
+	}
+	caFile.Close()
+
+	tests := []struct {
+		name      string
+		tls       *TLSConfig
+		expectErr bool
+	}{
+		{"default", nil, true},
+		{"ca file", &TLSConfig{CAFile: caFile.Name()}, false},
+		{"insecure", &TLSConfig{InsecureSkipVerify: true}, false},
+	}
+
+	for _, tt := range tests {
+		inst, err := NewInstanceConfig(srv.URL, nil)
+		if err != nil {
+			t.Fatalf("could not create instance config: %v", err)
+		}
+		inst.TLS = tt.tls
+
+		client, err := inst.HTTPClient()
+		if err != nil {
+			t.Fatalf("%v: could not create http client: %v", tt.name, err)
+		}
+
+		resp, err := client.Get(srv.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		if (err != nil) != tt.expectErr {
+			t.Errorf("%v: unexpected request result. expected error=%v, got=%v", tt.name, tt.expectErr, err)
+		}
+	}
+}
@@ -1,16 +1,22 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/tommyknows/gitlab-cli/api/config"
+	"github.com/tommyknows/gitlab-cli/pkg/gitlab"
 	"github.com/tommyknows/gitlab-cli/pkg/log"
+	"github.com/tommyknows/gitlab-cli/pkg/picker"
 )
 
 func Execute() error {
@@ -52,7 +58,12 @@ func gitlabCLI() *cobra.Command {
 	var cfgFile string
 	cfg := new(config.Config)
 
-	var useConfigContext bool
+	var (
+		useConfigContext bool
+		contextName      string
+		remoteName       string
+		assumeYes        bool
+	)
 
 	configDefaultPath := ""
 	if home := homeDir(); home != "" {
@@ -99,16 +110,65 @@ flag '--use-config-context' / '-u'. This will disable the parsing of the local
 git repository and always use the current context that is specified in the 
 config file
 
+The context is resolved in the following order, the first match wins:
+1. the context given by '--context' or $GITLAB_CLI_CONTEXT
+2. an ephemeral context for the instance at $GITLAB_HOST, authenticating with
+   $GITLAB_TOKEN. The config file is not modified.
+3. the local git repository, unless '--use-config-context' is set. It is
+   searched for upwards from the working directory, and the URLs of its remotes
+   are matched against the instances. The remote given by '--remote' or the
+   'gitRemote' setting of the config is checked first, then origin, then the
+   others. With '--remote', no other remotes are considered.
+4. unless '--use-config-context' is set, the context pinned by a '.gitlab-cli'
+   file in the working directory or one of its parents. The file pins either a
+   context or an instance and a namespace:
+     context: work        # or: instance: gitlab.com
+     namespace: platform  # optional when a context is pinned
+   The project commands ignore the local git repository, but use the pin.
+5. the current context of the config file
+If only $GITLAB_TOKEN is set, it replaces the token of the resolved instance.
+
+Unless '--config' is given, $GITLAB_CLI_CONFIG may list multiple config files,
+separated by ':', which are merged. Earlier files take precedence: instances and
+contexts are merged field by field, and for every field and all other settings,
+the first file that sets it wins. Changed fields are written back to the file
+that sets them, deleted instances and contexts are deleted from all files. New
+entries, new fields and all other settings are written to the first file. This
+allows to combine a personal file holding tokens with a shared team file:
+  GITLAB_CLI_CONFIG=~/.gitlab-cli.yml:/shared/team.yml
+All files should have 'apiVersion: v1' set, otherwise they are migrated from
+the legacy format.
+
+Contexts may be protected against changes, e.g. for production instances, with
+their 'protection' setting: 'read-only' refuses all changes, 'confirm' asks
+before the first change of an invocation, which '--yes' confirms in advance.
+Contexts detected from a local repository or $GITLAB_HOST are protected like
+the strictest context of their instance.
+
 This tool is currently in alpha stage.
 `,
-		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
-			c, err := config.Load(cfgFile, useConfigContext)
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			files := []string{cfgFile}
+			if env := os.Getenv(config.ConfigEnv); env != "" && !cmd.Flags().Changed("config") {
+				files = config.SplitConfigPaths(env)
+			}
+
+			c, err := config.LoadFiles(files, useConfigContext)
 			if err != nil {
 				return errors.Wrapf(err, "could not load config file")
 			}
 
 			*cfg = *c
 
+			if contextName != "" {
+				cfg.OverrideContext(contextName)
+			}
+
+			if remoteName != "" {
+				cfg.UseRemote(remoteName)
+			}
+
+			cfg.ConfirmWith(confirmChange(assumeYes))
 			return nil
 		},
 		PersistentPostRunE: func(_ *cobra.Command, _ []string) error {
@@ -116,18 +176,46 @@ This tool is currently in alpha stage.
 		},
 	}
 
-	cmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", configDefaultPath, "config file location")
+	cmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", configDefaultPath, "config file location, see $"+config.ConfigEnv+" for multiple files")
 	// TODO: find a better name for this
 	cmd.PersistentFlags().BoolVarP(&useConfigContext, "use-config-context", "u", false, "use the context of the config instead of a possible local one")
+	cmd.PersistentFlags().StringVar(&contextName, "context", "", "use this context for this invocation only (or set $"+config.ContextEnv+")")
+	cmd.PersistentFlags().StringVar(&remoteName, "remote", "", "the remote of the local git repository to detect the context from")
+	cmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "confirm changes through contexts that require a confirmation")
 	cmd.AddCommand(
-		newContextCommand(cfg),
-		newInstanceCommand(cfg),
+		newContextCommand(ctx, cfg),
+		newInstanceCommand(ctx, cfg),
 		newProjectCommand(ctx, cfg),
 	)
 
 	return cmd
 }
 
+// confirmChange returns the function that asks whether changes may be
+// made through a context that requires a confirmation. With assumeYes,
+// they are confirmed right away. Without a terminal, they are refused.
+func confirmChange(assumeYes bool) gitlab.ConfirmFunc {
+	return func(context, request string) (bool, error) {
+		if assumeYes {
+			return true, nil
+		}
+
+		if !picker.IsInteractive() {
+			log.Warningf("no terminal to confirm changes through context %v, use --yes", context)
+			return false, nil
+		}
+
+		fmt.Fprintf(os.Stderr, "context %v requires confirmation for changes, send %v? [y/N] ", context, request)
+		answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes", nil
+	}
+}
+
 func homeDir() string {
 	if h := os.Getenv("HOME"); h != "" {
 		return h
@@ -1,16 +1,24 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"sort"
+	"strings"
 	"text/tabwriter"
 
+	"github.com/ghodss/yaml"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/tommyknows/gitlab-cli/api/config"
+	"github.com/tommyknows/gitlab-cli/pkg/gitlab"
+	"github.com/tommyknows/gitlab-cli/pkg/log"
+	"github.com/tommyknows/gitlab-cli/pkg/picker"
 )
 
-func newContextCommand(cfg *config.Config) *cobra.Command {
+func newContextCommand(ctx context.Context, cfg *config.Config) *cobra.Command {
 	c := &cobra.Command{
 		Long: `the context subcommands allows to list, add, change or delete contexts that are 
 specified in the config file.
@@ -42,44 +50,43 @@ current namespace.`,
 				return printContexts(cfg)
 			},
 		},
+		newContextCreateCommand(ctx, cfg),
 		&cobra.Command{
-			Use:     "create [name] [instance] [namespace/project]",
-			Short:   "create a context that is tied to an instance, with an optional group",
-			Args:    cobra.RangeArgs(2, 3),
-			Aliases: []string{"cr"},
+			Use:   "switch [name]",
+			Short: "switch to a context, or to the previous one with \"-\"",
+			Long: "switch to a context, or to the previous one with \"-\". If no name is given " +
+				"and the command runs in a terminal, the context can be picked interactively.",
+			Aliases:      []string{"sw"},
+			SilenceUsage: true,
+			Args:         cobra.MaximumNArgs(1),
 			RunE: func(_ *cobra.Command, args []string) error {
-				var name, instance, ns string
-				name = args[0]
-				instance = args[1]
-
-				if len(args) == 3 {
-					ns = args[2]
+				if len(args) == 0 {
+					name, err := pickContext(cfg)
+					if err != nil {
+						return err
+					}
+					return cfg.SwitchContext(name)
 				}
 
-				cfg.Contexts[name] = &config.Context{
-					Namespace:    ns,
-					InstanceName: instance,
+				if args[0] != previousContext {
+					return cfg.SwitchContext(args[0])
 				}
 
-				if _, ok := cfg.Instances[instance]; !ok {
-					fmt.Println("Instance", instance, "is not specified in config")
+				prev, err := cfg.SwitchBack()
+				if err != nil {
+					return err
 				}
+
+				fmt.Printf("switched to context %v (namespace %q)\n", prev.Context, prev.Namespace)
 				return nil
 			},
 		},
 		&cobra.Command{
-			Use:          "switch [name]",
-			Short:        "switch to a context",
-			Aliases:      []string{"sw"},
-			SilenceUsage: true,
-			Args:         cobra.ExactArgs(1),
-			RunE: func(_ *cobra.Command, args []string) error {
-				if _, ok := cfg.Contexts[args[0]]; !ok {
-					return errors.Errorf("no such context: %q", args[0])
-				}
-				cfg.CurrentContext = args[0]
-
-				return nil
+			Use:   "history",
+			Short: "list the previously used contexts, the most recent first",
+			Args:  cobra.NoArgs,
+			RunE: func(_ *cobra.Command, _ []string) error {
+				return printHistory(cfg)
 			},
 		},
 		&cobra.Command{
@@ -92,6 +99,41 @@ current namespace.`,
 				return nil
 			},
 		},
+		&cobra.Command{
+			Use:   "current",
+			Short: "print the name of the current context",
+			Args:  cobra.NoArgs,
+			RunE: func(_ *cobra.Command, _ []string) error {
+				fmt.Println(cfg.CurrentContextName())
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:          "rename [old-name] [new-name]",
+			Short:        "rename a context",
+			Aliases:      []string{"mv"},
+			SilenceUsage: true,
+			Args:         cobra.ExactArgs(2),
+			RunE: func(_ *cobra.Command, args []string) error {
+				return cfg.RenameContext(args[0], args[1])
+			},
+		},
+		newContextSetCommand(ctx, cfg),
+		&cobra.Command{
+			Use:          "view [name]",
+			Short:        "print a context and its instance, with secrets masked. defaults to the current context",
+			SilenceUsage: true,
+			Args:         cobra.RangeArgs(0, 1),
+			RunE: func(_ *cobra.Command, args []string) error {
+				name := cfg.CurrentContextName()
+				if len(args) == 1 {
+					name = args[0]
+				}
+				return viewContext(cfg, name)
+			},
+		},
+		newContextExportCommand(cfg),
+		newContextImportCommand(cfg),
 		&cobra.Command{
 			Use:   "clean",
 			Short: "clean the config file, pruning contexts that reference an instance that does not exist",
@@ -110,11 +152,333 @@ current namespace.`,
 	return c
 }
 
+func newContextCreateCommand(ctx context.Context, cfg *config.Config) *cobra.Command {
+	var (
+		force      bool
+		protection string
+
+		create = &cobra.Command{
+			Use:   "create [name] [instance] [namespace/project]",
+			Short: "create a context that is tied to an instance, with an optional group",
+			Long: `create a context that is tied to an instance, with an optional group. The 
+namespace is verified on the instance, and similar namespaces are suggested if it
+does not exist. Use --force to create the context anyway. With --protection, changes
+through the context are refused (read-only) or need to be confirmed (confirm).`,
+			Args:         cobra.RangeArgs(2, 3),
+			Aliases:      []string{"cr"},
+			SilenceUsage: true,
+			RunE: func(_ *cobra.Command, args []string) error {
+				var name, instance, ns string
+				name = args[0]
+				instance = args[1]
+
+				if len(args) == 3 {
+					ns = strings.Trim(args[2], "/")
+				}
+
+				var p gitlab.Protection
+				if protection != "" {
+					var err error
+					if p, err = gitlab.ParseProtection(protection); err != nil {
+						return err
+					}
+				}
+
+				if err := verifyContextNamespace(ctx, cfg, instance, ns, force); err != nil {
+					return err
+				}
+
+				cfg.Contexts[name] = &config.Context{
+					Namespace:    ns,
+					InstanceName: instance,
+					Protection:   p,
+				}
+				return nil
+			},
+		}
+	)
+
+	create.Flags().BoolVarP(&force, "force", "f", false, "create the context even if the namespace cannot be verified")
+	create.Flags().StringVar(&protection, "protection", "", protectionUsage)
+	return create
+}
+
+func newContextSetCommand(ctx context.Context, cfg *config.Config) *cobra.Command {
+	var (
+		namespace, instance string
+		protection          string
+		force               bool
+
+		set = &cobra.Command{
+			Use:   "set [name]",
+			Short: "set the namespace, instance or protection of a context. creates the context if it does not exist",
+			Long: `set the namespace, instance or protection of a context. If the context does not 
+exist, it is created, in which case the instance is required. The namespace is 
+verified on the instance, use --force to set it anyway.`,
+			SilenceUsage: true,
+			Args:         cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				name := args[0]
+
+				c, ok := cfg.Contexts[name]
+				if !ok {
+					if instance == "" {
+						return errors.Errorf("context %q does not exist, --instance is required to create it", name)
+					}
+					c = new(config.Context)
+				}
+
+				newCtx := *c
+				if cmd.Flags().Changed("instance") {
+					newCtx.InstanceName = instance
+				}
+
+				if cmd.Flags().Changed("namespace") {
+					newCtx.Namespace = strings.Trim(namespace, "/")
+				}
+
+				if cmd.Flags().Changed("protection") {
+					p, err := gitlab.ParseProtection(protection)
+					if err != nil {
+						return err
+					}
+					newCtx.Protection = p
+				}
+
+				if err := verifyContextNamespace(ctx, cfg, newCtx.InstanceName, newCtx.Namespace, force); err != nil {
+					return err
+				}
+
+				*c = newCtx
+				cfg.Contexts[name] = c
+				return nil
+			},
+		}
+	)
+
+	set.Flags().StringVarP(&namespace, "namespace", "n", "", "namespace (group, user or project) of the context")
+	set.Flags().StringVarP(&instance, "instance", "i", "", "instance of the context")
+	set.Flags().StringVar(&protection, "protection", "", protectionUsage)
+	set.Flags().BoolVarP(&force, "force", "f", false, "set the namespace even if it cannot be verified")
+	return set
+}
+
+const protectionUsage = "protection against changes through the context: none, confirm or read-only"
+
+func newContextExportCommand(cfg *config.Config) *cobra.Command {
+	var (
+		output string
+
+		export = &cobra.Command{
+			Use:   "export [name...]",
+			Short: "export contexts and their instances, without any credentials",
+			Long: `export the given contexts (or all, if none are given) and their instances to a 
+file that can be shared and imported with "context import". Credentials are never 
+exported, every user needs to login to the instances with "instance create".`,
+			SilenceUsage: true,
+			Args:         cobra.ArbitraryArgs,
+			RunE: func(_ *cobra.Command, args []string) error {
+				exported, err := cfg.Export(args...)
+				if err != nil {
+					return err
+				}
+
+				cont, err := exported.Marshal()
+				if err != nil {
+					return errors.Wrapf(err, "could not marshal contexts")
+				}
+
+				if output == "" || output == "-" {
+					_, err := os.Stdout.Write(cont)
+					return err
+				}
+
+				return ioutil.WriteFile(output, cont, 0644)
+			},
+		}
+	)
+
+	export.Flags().StringVarP(&output, "output", "o", "", "file to write the contexts to. defaults to stdout")
+	return export
+}
+
+func newContextImportCommand(cfg *config.Config) *cobra.Command {
+	var (
+		overwrite bool
+
+		imp = &cobra.Command{
+			Use:   "import [file]",
+			Short: "import contexts and instances that have been exported with \"context export\"",
+			Long: `import contexts and instances that have been exported with "context export". 
+Existing contexts are skipped, unless --overwrite is set. Existing instances are 
+never overwritten. Imported instances have no credentials, login to them with 
+"instance create".`,
+			SilenceUsage: true,
+			Args:         cobra.ExactArgs(1),
+			RunE: func(_ *cobra.Command, args []string) error {
+				var (
+					cont []byte
+					err  error
+				)
+				if args[0] == "-" {
+					cont, err = ioutil.ReadAll(os.Stdin)
+				} else {
+					cont, err = ioutil.ReadFile(args[0])
+				}
+				if err != nil {
+					return errors.Wrapf(err, "could not read contexts")
+				}
+
+				other, err := config.Parse(cont)
+				if err != nil {
+					return err
+				}
+
+				res := cfg.Import(other, overwrite)
+
+				for _, name := range res.ImportedContexts {
+					fmt.Printf("imported context %v\n", name)
+				}
+				for _, name := range res.SkippedContexts {
+					fmt.Printf("skipped existing context %v, use --overwrite to replace it\n", name)
+				}
+				for _, name := range res.ImportedInstances {
+					fmt.Printf("imported instance %v, login with \"gitlab-cli instance create %v [TOKEN]\"\n", name, other.Instances[name].URL)
+				}
+				for _, name := range res.ConflictingInstances {
+					log.Warningf("instance %v already exists with a different URL, keeping the existing one", name)
+				}
+
+				return nil
+			},
+		}
+	)
+
+	imp.Flags().BoolVar(&overwrite, "overwrite", false, "overwrite existing contexts")
+	return imp
+}
+
+// verifyContextNamespace verifies the namespace of a context that is to be
+// created or changed. If force is set, failures are only logged.
+func verifyContextNamespace(ctx context.Context, cfg *config.Config, instance, namespace string, force bool) error {
+	if _, ok := cfg.Instances[instance]; !ok {
+		fmt.Println("Instance", instance, "is not specified in config")
+		return nil
+	}
+
+	if namespace == "" {
+		return nil
+	}
+
+	client, err := cfg.InstanceClient(instance, namespace)
+	if err != nil {
+		return err
+	}
+
+	err = verifyNamespace(ctx, client, namespace)
+	if err == nil {
+		return nil
+	}
+
+	if !force {
+		return errors.Wrapf(err, "use --force to use the namespace anyway")
+	}
+
+	log.Warningf("%v", err)
+	return nil
+}
+
+// verifyNamespace checks that the namespace of the client exists, printing
+// what kind of namespace it is. If it does not exist, similar namespaces are
+// suggested in the returned error.
+func verifyNamespace(ctx context.Context, client *gitlab.Client, namespace string) error {
+	kind, err := client.LookupNamespace(ctx)
+	if err == nil {
+		fmt.Printf("%v is a %v\n", namespace, kind)
+		return nil
+	}
+
+	if err != gitlab.ErrNotFound {
+		return errors.Wrapf(err, "could not verify namespace %v", namespace)
+	}
+
+	suggestions, err := client.SuggestNamespaces(ctx, 5)
+	if err != nil {
+		log.Debugf("could not get suggestions for namespace %v: %v", namespace, err)
+	}
+
+	if len(suggestions) == 0 {
+		return errors.Errorf("namespace %q does not exist", namespace)
+	}
+
+	return errors.Errorf("namespace %q does not exist, did you mean one of these?\n\t%v",
+		namespace, strings.Join(suggestions, "\n\t"))
+}
+
+// pickContext lets the user pick one of the configured contexts.
+func pickContext(cfg *config.Config) (string, error) {
+	if !picker.IsInteractive() {
+		return "", errors.New("no context given, and no terminal to pick one")
+	}
+
+	names := make([]string, 0, len(cfg.Contexts))
+	for name := range cfg.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return picker.Pick("context:", names)
+}
+
+func viewContext(cfg *config.Config, name string) error {
+	ctx, ok := cfg.Contexts[name]
+	if !ok {
+		return errors.Errorf("no such context: %q", name)
+	}
+
+	view := struct {
+		Name     string                 `json:"name"`
+		Context  *config.Context        `json:"context"`
+		Instance *config.InstanceConfig `json:"instance,omitempty"`
+	}{
+		Name:    name,
+		Context: ctx,
+	}
+
+	if inst, ok := cfg.Instances[ctx.InstanceName]; ok {
+		view.Instance = inst.Masked()
+	}
+
+	cont, err := yaml.Marshal(view)
+	if err != nil {
+		return errors.Wrapf(err, "could not marshal context")
+	}
+
+	_, err = os.Stdout.Write(cont)
+	return err
+}
+
+// previousContext is the argument to switch to the previous context.
+const previousContext = "-"
+
+func printHistory(c *config.Config) error {
+	w := tabwriter.NewWriter(os.Stdout, 1, 8, 2, ' ', 0)
+
+	fmt.Fprint(w, "\tcontext\tnamespace\n")
+	fmt.Fprint(w, "\t-------\t---------\n")
+
+	for i, entry := range c.History {
+		fmt.Fprintf(w, "%d\t%v\t%v\n", i+1, entry.Context, entry.Namespace)
+	}
+
+	return w.Flush()
+}
+
 func printContexts(c *config.Config) error {
 	w := tabwriter.NewWriter(os.Stdout, 1, 8, 2, ' ', 0)
 
-	fmt.Fprint(w, "\tname\tinstance\tnamespace\n")
-	fmt.Fprint(w, "\t----\t--------\t---------\n")
+	fmt.Fprint(w, "\tname\tinstance\tnamespace\tprotection\n")
+	fmt.Fprint(w, "\t----\t--------\t---------\t----------\n")
 
 	for name, ctx := range c.Contexts {
 		var current string
@@ -122,7 +486,12 @@ func printContexts(c *config.Config) error {
 			current = "*"
 		}
 
-		fmt.Fprintf(w, "%s\t%v\t%v\t%v\n", current, name, ctx.InstanceName, ctx.Namespace)
+		protection := ctx.Protection
+		if protection == "" {
+			protection = gitlab.Unprotected
+		}
+
+		fmt.Fprintf(w, "%s\t%v\t%v\t%v\t%v\n", current, name, ctx.InstanceName, ctx.Namespace, protection)
 	}
 
 	return w.Flush()
@@ -1,18 +1,30 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"text/tabwriter"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/tommyknows/gitlab-cli/api/config"
+	"github.com/tommyknows/gitlab-cli/pkg/gitlab"
+	"github.com/tommyknows/gitlab-cli/pkg/log"
+	"github.com/tommyknows/gitlab-cli/pkg/secret"
 )
 
-func newInstanceCommand(cfg *config.Config) *cobra.Command {
+func newInstanceCommand(ctx context.Context, cfg *config.Config) *cobra.Command {
 	cmd := &cobra.Command{
-		Long: `instances specify the URL and login for Gitlab instances. Currently, for login 
-only API Tokens ("access tokens") are available. 
+		Long: `instances specify the URL and login for Gitlab instances. For login, API 
+tokens ("access tokens"), basic-auth, OAuth2 tokens and CI job tokens are available.
+Tokens may be stored in the config file, or be read from an environment variable, 
+a command or an encrypted file.
 
 Depending on what you are trying to do with that CLI, you may assign the token 
 more or less permissions.`,
@@ -22,56 +34,52 @@ more or less permissions.`,
 	}
 
 	cmd.AddCommand(
+		newInstanceCreateCommand(ctx, cfg),
 		&cobra.Command{
-			Use:     createSub.Usage("[SERVER] [TOKEN]"),
-			Aliases: createSub.abbr,
-			Short:   "login to a Gitlab instance. if none is given, uses gitlab.com",
-			Args:    cobra.RangeArgs(1, 2),
+			Use:     listSub.Usage(""),
+			Aliases: listSub.abbr,
+			Short:   "list all instances in the config file",
+			Args:    cobra.NoArgs,
+
 			RunE: func(_ *cobra.Command, args []string) error {
-				var token, server string
-				if len(args) == 1 {
-					server = "gitlab.com"
-					token = args[0]
-				} else {
-					server = args[0]
-					token = args[1]
+				return printInstances(cfg)
+			},
+		},
+		&cobra.Command{
+			Use:          "status [instance...]",
+			Short:        "validate the credentials of all (or the given) instances",
+			Args:         cobra.ArbitraryArgs,
+			SilenceUsage: true,
+			RunE: func(_ *cobra.Command, args []string) error {
+				names := args
+				if len(names) == 0 {
+					for name := range cfg.Instances {
+						names = append(names, name)
+					}
+					sort.Strings(names)
 				}
 
-				cfg.Instances[server] = &config.InstanceConfig{
-					Authentication: &config.Authentication{
-						Type: config.Token,
-						TokenAuthentication: &config.TokenAuthentication{
-							Token: token,
-						},
-					},
-				}
+				var failed []string
+				for _, name := range names {
+					if _, ok := cfg.Instances[name]; !ok {
+						return errors.Errorf("no such instance: %q", name)
+					}
 
-				if _, ok := cfg.Contexts[server]; ok {
-					fmt.Printf("context with name %v already exists, not modifying\n", server)
-				} else {
-					cfg.Contexts[server] = &config.Context{
-						InstanceName: server,
-						Namespace:    "",
+					if err := checkInstance(ctx, cfg, name); err != nil {
+						log.Errorf("%v: %v", name, err)
+						failed = append(failed, name)
 					}
 				}
 
-				cfg.CurrentContext = server
-
-				fmt.Printf("added login as context %v and set as current context", server)
-
+				if len(failed) > 0 {
+					return errors.Errorf("invalid credentials for instances %v", strings.Join(failed, ", "))
+				}
 				return nil
 			},
 		},
-		&cobra.Command{
-			Use:     listSub.Usage(""),
-			Aliases: listSub.abbr,
-			Short:   "list all instances in the config file",
-			Args:    cobra.NoArgs,
-
-			RunE: func(_ *cobra.Command, args []string) error {
-				return printInstances(cfg)
-			},
-		},
+		newInstanceSetCommand(cfg),
+		newInstanceRotateTokenCommand(ctx, cfg),
+		newInstanceImportCommand(cfg),
 		&cobra.Command{
 			Use:   "clean",
 			Short: "clean the config file, pruning instances that are not referenced in a context",
@@ -95,11 +103,479 @@ more or less permissions.`,
 	return cmd
 }
 
+func newInstanceCreateCommand(ctx context.Context, cfg *config.Config) *cobra.Command {
+	var (
+		tlsConfig  config.TLSConfig
+		authOpts   = new(authOptions)
+		force      bool
+		name       string
+		namespaces []string
+		preferred  bool
+
+		create = &cobra.Command{
+			Use:     createSub.Usage("[URL] [TOKEN]"),
+			Aliases: createSub.abbr,
+			Short:   "login to a Gitlab instance. if none is given, uses gitlab.com",
+			Long: `login to a Gitlab instance. The URL may contain a scheme, a port and the
+relative URL root of the instance, e.g. http://gitlab.internal:8080/gitlab. If
+no scheme is given, https is assumed. The instance is named after the host,
+unless a name is given with --name.
+
+Several instances may share a host, e.g. a personal and a work account on
+gitlab.com. To decide which of them a local git repository belongs to, give the
+work instance the namespaces of its projects (--namespace company) or mark one
+of them with --preferred. Instances without namespaces are used for all other
+projects. Both can be changed later with "instance set".
+
+The authentication type is set with --auth-type:
+- token:      a personal, project or group access token (default)
+- basic-auth: username (--username) and password, exchanged for an OAuth2 token
+              through the password grant. The password is given as TOKEN.
+- oauth2:     an OAuth2 access token. If --refresh-token and --client-id are given,
+              the token is refreshed when it expires or is rejected by Gitlab.
+- job-token:  the CI_JOB_TOKEN of a Gitlab CI job. No TOKEN is given, and the URL
+              defaults to $CI_SERVER_URL.
+
+By default, the token (or basic-auth password) is stored in plaintext in the
+config file. To avoid that, it can be
+- read from an environment variable (--token-env), 
+- printed by a command like "pass show gitlab" (--token-command) or 
+- stored in an encrypted file, protected by a passphrase (--token-store). The
+  passphrase is read from $` + secret.PassphraseEnv + ` or prompted for.
+With --token-env and --token-command, no TOKEN argument is given.
+
+The credentials are validated before they are saved, printing the scopes and
+the expiry of the token. Use --force to save invalid credentials anyway.`,
+			Args:         cobra.RangeArgs(0, 2),
+			SilenceUsage: true,
+			RunE: func(_ *cobra.Command, args []string) error {
+				server := "gitlab.com"
+				if u := os.Getenv("CI_SERVER_URL"); u != "" && authOpts.authType == string(config.JobToken) {
+					server = u
+				}
+
+				var token string
+				switch {
+				case !authOpts.needsSecret() && len(args) == 1:
+					server = args[0]
+				case !authOpts.needsSecret() && len(args) == 0:
+				case authOpts.needsSecret() && len(args) == 1:
+					token = args[0]
+				case authOpts.needsSecret() && len(args) == 2:
+					server = args[0]
+					token = args[1]
+				default:
+					return errors.New("invalid number of arguments")
+				}
+
+				u, err := config.ParseInstanceURL(server)
+				if err != nil {
+					return err
+				}
+				if name == "" {
+					name = u.Host
+				}
+
+				auth, err := authOpts.authentication(token)
+				if err != nil {
+					return err
+				}
+
+				instance, err := config.NewInstanceConfig(server, auth)
+				if err != nil {
+					return err
+				}
+
+				if tlsConfig != (config.TLSConfig{}) {
+					instance.TLS = &tlsConfig
+				}
+				instance.Namespaces = namespaces
+				instance.Preferred = preferred
+
+				// the instance is validated through the config, so that it is
+				// protected like the contexts that may already use its name.
+				previous, replaced := cfg.Instances[name]
+				cfg.Instances[name] = instance
+
+				if auth.Type == config.JobToken {
+					fmt.Println("not validating job token, it is only valid during a CI job")
+				} else if err := checkInstance(ctx, cfg, name); err != nil {
+					if !force {
+						if replaced {
+							cfg.Instances[name] = previous
+						} else {
+							delete(cfg.Instances, name)
+						}
+						return errors.Wrapf(err, "could not validate credentials, use --force to save them anyway")
+					}
+					log.Warningf("could not validate credentials, saving anyway: %v", err)
+				}
+
+				if _, ok := cfg.Contexts[name]; ok {
+					fmt.Printf("context with name %v already exists, not modifying\n", name)
+				} else {
+					cfg.Contexts[name] = &config.Context{
+						InstanceName: name,
+						Namespace:    "",
+					}
+				}
+
+				cfg.CurrentContext = name
+
+				// the secret is only stored once nothing else can fail.
+				if err := authOpts.storeSecret(name, auth); err != nil {
+					return err
+				}
+
+				fmt.Printf("added login as context %v and set as current context", name)
+
+				return nil
+			},
+		}
+	)
+
+	create.Flags().StringVar(&tlsConfig.CAFile, "ca-file", "", "PEM encoded CA bundle to verify the instance's certificate")
+	create.Flags().StringVar(&tlsConfig.CertFile, "cert-file", "", "PEM encoded client certificate for mutual TLS")
+	create.Flags().StringVar(&tlsConfig.KeyFile, "key-file", "", "PEM encoded client key for mutual TLS")
+	create.Flags().BoolVar(&tlsConfig.InsecureSkipVerify, "insecure-skip-verify", false, "do not verify the instance's certificate. INSECURE")
+	create.Flags().BoolVarP(&force, "force", "f", false, "save the instance even if the credentials are invalid")
+	create.Flags().StringVar(&name, "name", "", "name of the instance and its context. defaults to the host")
+	create.Flags().StringSliceVar(&namespaces, "namespace", nil, "namespaces of the local repositories that belong to this instance, if several instances share the host")
+	create.Flags().BoolVar(&preferred, "preferred", false, "prefer this instance for local repositories, if several instances share the host")
+	authOpts.addFlags(create.Flags())
+
+	return create
+}
+
+func newInstanceSetCommand(cfg *config.Config) *cobra.Command {
+	var (
+		namespaces []string
+		preferred  bool
+
+		set = &cobra.Command{
+			Use:   "set [instance]",
+			Short: "set the namespaces or the preference of an instance",
+			Long: `set the namespaces or the preference of an instance, which decide whether a local
+git repository belongs to it if several instances share a host. --namespace
+replaces the namespaces of the instance, an empty --namespace "" removes them.`,
+			SilenceUsage: true,
+			Args:         cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				name := args[0]
+				instance, ok := cfg.Instances[name]
+				if !ok {
+					return errors.Errorf("no such instance: %q", name)
+				}
+
+				if cmd.Flags().Changed("namespace") {
+					instance.Namespaces = nil
+					for _, ns := range namespaces {
+						if ns = strings.Trim(ns, "/"); ns != "" {
+							instance.Namespaces = append(instance.Namespaces, ns)
+						}
+					}
+				}
+
+				if cmd.Flags().Changed("preferred") {
+					instance.Preferred = preferred
+				}
+				return nil
+			},
+		}
+	)
+
+	set.Flags().StringSliceVar(&namespaces, "namespace", nil, "namespaces of the local repositories that belong to this instance, if several instances share the host")
+	set.Flags().BoolVar(&preferred, "preferred", false, "prefer this instance for local repositories, if several instances share the host")
+	return set
+}
+
+// authOptions are the flags that specify how to authenticate to an instance.
+type authOptions struct {
+	authType string
+	username string
+
+	tokenEnv, tokenCommand string
+	useTokenStore          bool
+	tokenStoreFile         string
+
+	refreshToken, clientID, clientSecret string
+	expiresIn                            time.Duration
+}
+
+func (o *authOptions) addFlags(flags *pflag.FlagSet) {
+	secretsDefaultPath := ""
+	if home := homeDir(); home != "" {
+		secretsDefaultPath = filepath.Join(home, ".gitlab-cli.secrets")
+	}
+
+	flags.StringVar(&o.authType, "auth-type", string(config.Token), "authentication type: token, basic-auth, oauth2 or job-token")
+	flags.StringVar(&o.username, "username", "", "username for basic-auth")
+	flags.StringVar(&o.tokenEnv, "token-env", "", "read the token (or basic-auth password) from this environment variable")
+	flags.StringVar(&o.tokenCommand, "token-command", "", "read the token (or basic-auth password) from the output of this shell command")
+	flags.BoolVar(&o.useTokenStore, "token-store", false, "store the token (or basic-auth password) in the encrypted secret store")
+	flags.StringVar(&o.tokenStoreFile, "token-store-file", secretsDefaultPath, "location of the encrypted secret store")
+	flags.StringVar(&o.refreshToken, "refresh-token", "", "OAuth2 refresh token")
+	flags.StringVar(&o.clientID, "client-id", "", "OAuth2 application ID, needed to refresh the token")
+	flags.StringVar(&o.clientSecret, "client-secret", "", "OAuth2 application secret, for confidential applications")
+	flags.DurationVar(&o.expiresIn, "expires-in", 0, "time until the OAuth2 access token expires")
+}
+
+// needsSecret returns true if the token (or password) is
+// expected to be passed as an argument.
+func (o *authOptions) needsSecret() bool {
+	return o.authType != string(config.JobToken) && o.tokenEnv == "" && o.tokenCommand == ""
+}
+
+// authentication creates the authentication with the given token (or
+// password). With --token-store, the token is kept in the config until
+// storeSecret is called, so that it can be validated first.
+func (o *authOptions) authentication(token string) (*config.Authentication, error) {
+	switch config.AuthenticationType(o.authType) {
+	case config.Token:
+		return o.tokenAuthentication(token), nil
+
+	case config.BasicAuth:
+		if o.username == "" {
+			return nil, errors.New("basic-auth needs a username")
+		}
+		return o.basicAuthentication(token), nil
+
+	case config.OAuth2:
+		oauth := &config.OAuth2Authentication{
+			AccessToken:  token,
+			RefreshToken: o.refreshToken,
+			ClientID:     o.clientID,
+			ClientSecret: o.clientSecret,
+		}
+		if o.expiresIn != 0 {
+			expiry := time.Now().Add(o.expiresIn)
+			oauth.Expiry = &expiry
+		}
+		return &config.Authentication{
+			Type:                 config.OAuth2,
+			OAuth2Authentication: oauth,
+		}, nil
+
+	case config.JobToken:
+		return &config.Authentication{Type: config.JobToken}, nil
+
+	default:
+		return nil, errors.Errorf("unknown authentication type %q", o.authType)
+	}
+}
+
+func (o *authOptions) tokenAuthentication(token string) *config.Authentication {
+	switch {
+	case o.tokenEnv != "":
+		return &config.Authentication{
+			Type:              config.TokenEnv,
+			EnvAuthentication: &config.EnvAuthentication{TokenEnv: o.tokenEnv},
+		}
+
+	case o.tokenCommand != "":
+		return &config.Authentication{
+			Type:                  config.TokenCommand,
+			CommandAuthentication: &config.CommandAuthentication{TokenCommand: o.tokenCommand},
+		}
+
+	default:
+		return &config.Authentication{
+			Type:                config.Token,
+			TokenAuthentication: &config.TokenAuthentication{Token: token},
+		}
+	}
+}
+
+func (o *authOptions) basicAuthentication(password string) *config.Authentication {
+	basic := &config.BasicAuthentication{Username: o.username}
+
+	switch {
+	case o.tokenEnv != "":
+		basic.PasswordEnv = o.tokenEnv
+
+	case o.tokenCommand != "":
+		basic.PasswordCommand = o.tokenCommand
+
+	default:
+		basic.Password = password
+	}
+
+	return &config.Authentication{
+		Type:                config.BasicAuth,
+		BasicAuthentication: basic,
+	}
+}
+
+// storeSecret moves the token (or password) of the authentication of the
+// instance name into the secret store, if --token-store is set.
+func (o *authOptions) storeSecret(name string, auth *config.Authentication) error {
+	if !o.useTokenStore {
+		return nil
+	}
+
+	switch {
+	case auth.Type == config.Token:
+		if err := storeToken(o.tokenStoreFile, name, auth.Token); err != nil {
+			return errors.Wrapf(err, "could not store token")
+		}
+		auth.Type = config.TokenStore
+		auth.TokenAuthentication = nil
+		auth.StoreAuthentication = &config.StoreAuthentication{
+			TokenStoreFile: o.tokenStoreFile,
+			TokenStoreKey:  name,
+		}
+
+	case auth.Type == config.BasicAuth && auth.Password != "":
+		if err := storeToken(o.tokenStoreFile, name, auth.Password); err != nil {
+			return errors.Wrapf(err, "could not store password")
+		}
+		auth.Password = ""
+		auth.PasswordStoreFile = o.tokenStoreFile
+		auth.PasswordStoreKey = name
+	}
+	return nil
+}
+
+func storeToken(file, name, token string) error {
+	passphrase, err := secret.ReadPassphrase("passphrase for " + file + ": ")
+	if err != nil {
+		return err
+	}
+
+	store, err := secret.Open(file, passphrase)
+	if err != nil {
+		return err
+	}
+
+	store.Set(name, token)
+	return store.Write()
+}
+
+func newInstanceRotateTokenCommand(ctx context.Context, cfg *config.Config) *cobra.Command {
+	var (
+		expiresAt string
+
+		rotate = &cobra.Command{
+			Use:   "rotate-token [instance]",
+			Short: "rotate the access token of an instance",
+			Long: `rotate the access token of an instance through Gitlab's token rotation API. 
+The old token is revoked immediately and the new token replaces it in the config
+file (or the encrypted secret store). Tokens that are read from an environment
+variable or a command cannot be rotated, as they are managed outside of the CLI.
+The rotation is protected like the strictest context of the instance.`,
+			Args:         cobra.ExactArgs(1),
+			SilenceUsage: true,
+			RunE: func(_ *cobra.Command, args []string) error {
+				name := args[0]
+				instance, ok := cfg.Instances[name]
+				if !ok {
+					return errors.Errorf("no such instance: %q", name)
+				}
+
+				if instance.Authentication == nil {
+					return errors.Errorf("instance %v has no authentication", name)
+				}
+
+				if !instance.Authentication.TokenReplaceable() {
+					return errors.Errorf("cannot rotate token of instance %v, it is read from %v", name, instance.Authentication.Source())
+				}
+
+				var expiry *time.Time
+				if expiresAt != "" {
+					t, err := time.Parse("2006-01-02", expiresAt)
+					if err != nil {
+						return errors.Wrapf(err, "invalid expiry date")
+					}
+					expiry = &t
+				}
+
+				client, err := cfg.InstanceClient(name, "")
+				if err != nil {
+					return err
+				}
+
+				token, expiry, err := client.RotateToken(ctx, expiry)
+				if err != nil {
+					return err
+				}
+
+				if err := instance.Authentication.ReplaceToken(token); err != nil {
+					// the old token has already been revoked, so this is the
+					// last chance for the user to get hold of the new one.
+					fmt.Fprintf(os.Stderr, "new token: %v\n", token)
+					return errors.Wrapf(err, "could not store the new token, save the token above manually")
+				}
+
+				instance.TokenExpiresAt = expiry
+				if err := cfg.Write(); err != nil {
+					fmt.Fprintf(os.Stderr, "new token: %v\n", token)
+					return errors.Wrapf(err, "could not write config, save the token above manually")
+				}
+
+				if expiry != nil {
+					fmt.Printf("rotated token of instance %v, the new token expires on %v\n", name, expiry.Format("2006-01-02"))
+				} else {
+					fmt.Printf("rotated token of instance %v\n", name)
+				}
+				return nil
+			},
+		}
+	)
+
+	rotate.Flags().StringVar(&expiresAt, "expires-at", "", "expiry date of the new token (YYYY-MM-DD). defaults to Gitlab's default")
+	return rotate
+}
+
+// checkInstance validates the credentials of the instance and prints
+// information about the authenticated user and the token. A warning is
+// logged if the token is about to expire.
+func checkInstance(ctx context.Context, cfg *config.Config, name string) error {
+	client, err := cfg.InstanceClient(name, "")
+	if err != nil {
+		return err
+	}
+
+	info, err := client.TokenInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	cfg.Instances[name].TokenExpiresAt = info.ExpiresAt
+	printTokenInfo(name, info)
+
+	if cfg.TokenExpiryWarningDays >= 0 && info.ExpiresWithin(cfg.TokenExpiryWarning()) {
+		log.Warningf("token of instance %v expires soon, rotate it with \"gitlab-cli instance rotate-token %v\"", name, name)
+	}
+	return nil
+}
+
+func printTokenInfo(name string, info *gitlab.TokenInfo) {
+	fmt.Printf("%v: authenticated as %v (%v)\n", name, info.Name, info.Username)
+
+	if !info.IsAccessToken() {
+		fmt.Println("  no access token information available")
+		return
+	}
+
+	expiry := "never"
+	if info.ExpiresAt != nil {
+		expiry = fmt.Sprintf("%v (in %d days)", info.ExpiresAt.Format("2006-01-02"), int(time.Until(*info.ExpiresAt).Hours()/24))
+	}
+
+	fmt.Printf("  token:   %v\n", info.TokenName)
+	fmt.Printf("  scopes:  %v\n", strings.Join(info.Scopes, ", "))
+	fmt.Printf("  expires: %v\n", expiry)
+
+	for _, missing := range info.MissingScopes() {
+		log.Warningf("token of instance %v is missing a scope: %v", name, missing)
+	}
+}
+
 func printInstances(cfg *config.Config) error {
 	w := tabwriter.NewWriter(os.Stdout, 1, 8, 2, ' ', 0)
 
-	fmt.Fprint(w, "\tname\tauthentication type\n")
-	fmt.Fprint(w, "\t----\t-------------------\n")
+	fmt.Fprint(w, "\tname\turl\tauthentication type\tsource\n")
+	fmt.Fprint(w, "\t----\t---\t-------------------\t------\n")
 
 	currentInstance := ""
 	ctx, err := cfg.GetCurrentContext()
@@ -112,9 +588,78 @@ func printInstances(cfg *config.Config) error {
 		if name == currentInstance {
 			current = "*"
 		}
-		fmt.Fprintf(w, "%s\t%v\t%v\n", current, name, instance.Authentication.Type)
+		authType, source := "none", "-"
+		if instance.Authentication != nil {
+			authType, source = string(instance.Authentication.Type), instance.Authentication.Source()
+		}
+		fmt.Fprintf(w, "%s\t%v\t%v\t%v\t%v\n", current, name, instance.URL, authType, source)
 	}
 
 	return w.Flush()
 
 }
+
+func newInstanceImportCommand(cfg *config.Config) *cobra.Command {
+	var (
+		from string
+
+		tools = make([]string, 0, len(config.ForeignTools))
+		imp   = &cobra.Command{
+			Use:   "import --from TOOL [path]",
+			Short: "import instances from the config of another Gitlab tool",
+			Long: `import instances from the config of another Gitlab tool, with a context of the
+same name for each of them. If no path is given, the default location of the 
+tool's config is used. Existing instances and contexts are never overwritten. 
+Tokens are imported as they are stored by the tool, e.g. python-gitlab's token
+helpers become token commands.`,
+			Args:         cobra.RangeArgs(0, 1),
+			SilenceUsage: true,
+			RunE: func(_ *cobra.Command, args []string) error {
+				var path string
+				if len(args) == 1 {
+					path = args[0]
+				}
+
+				fc, err := config.ReadForeignConfig(config.ForeignTool(from), path)
+				if err != nil {
+					return err
+				}
+
+				log.Debugf("read %v config %v", from, fc.Path)
+				res := cfg.ImportForeign(fc)
+
+				for _, name := range res.ImportedInstances {
+					fmt.Printf("imported instance %v (%v)\n", name, fc.Instances[name].URL)
+				}
+				for _, name := range res.ImportedContexts {
+					fmt.Printf("created context %v\n", name)
+				}
+				for _, name := range res.SkippedContexts {
+					fmt.Printf("skipped existing context %v\n", name)
+				}
+				for _, name := range res.ConflictingInstances {
+					log.Warningf("instance %v already exists, not overwriting it", name)
+				}
+
+				unsupported := make([]string, 0, len(fc.Unsupported))
+				for name := range fc.Unsupported {
+					unsupported = append(unsupported, name)
+				}
+				sort.Strings(unsupported)
+				for _, name := range unsupported {
+					log.Warningf("could not import instance %v: %v", name, fc.Unsupported[name])
+				}
+
+				return nil
+			},
+		}
+	)
+
+	for _, t := range config.ForeignTools {
+		tools = append(tools, string(t))
+	}
+
+	imp.Flags().StringVar(&from, "from", "", "the tool to import from, one of "+strings.Join(tools, ", "))
+	_ = imp.MarkFlagRequired("from")
+	return imp
+}
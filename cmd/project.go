@@ -3,14 +3,21 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"os"
 	"path"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/tommyknows/gitlab-cli/api/config"
 	"github.com/tommyknows/gitlab-cli/pkg/gitlab"
 	"github.com/tommyknows/gitlab-cli/pkg/log"
+	"github.com/tommyknows/gitlab-cli/pkg/picker"
+	gl "github.com/xanzy/go-gitlab"
 )
 
 func newProjectCommand(ctx context.Context, cfg *config.Config) *cobra.Command {
@@ -30,24 +37,43 @@ or actual projects (git repository).`,
 		SilenceUsage: true,
 		Long: "use the specified project / group as context. If context name is not given, " +
 			"current context will be overwritten. If project is an absolute path," +
-			"it will be added to the currently active project.",
-		Args: cobra.RangeArgs(1, 2),
+			"it will be added to the currently active project. The previous namespace of " +
+			"the current context is recorded in the history, see \"context switch -\". If no " +
+			"project is given and the command runs in a terminal, a group or project below the " +
+			"current namespace can be picked interactively.",
+		Args: cobra.RangeArgs(0, 2),
 		RunE: func(_ *cobra.Command, args []string) error {
 			// the project command doesn't really make sense if a concrete git repo.
-			cfg.PreferConfigContext = true
-
-			proj := args[0]
+			cfg.IgnoreLocalRepository()
 
 			currentCtx, err := cfg.GetCurrentContext()
 			if err != nil {
 				return errors.Wrapf(err, "could not get active context")
 			}
 
+			var proj string
+			if len(args) > 0 {
+				proj = args[0]
+			} else if proj, err = pickNamespace(ctx, currentCtx); err != nil {
+				return err
+			}
+
 			namespace := getAbsoluteGroupPath(currentCtx.Namespace, proj)
 
+			client, err := currentCtx.WitNamespace(namespace).GitlabClient()
+			if err != nil {
+				return err
+			}
+
+			if err := verifyNamespace(ctx, client, namespace); err != nil {
+				return err
+			}
+
 			if len(args) == 1 {
-				currentCtx.Namespace = namespace
-				return nil
+				if currentCtx.IsEphemeral() {
+					return errors.New("the current context is not stored in the config, specify a context name")
+				}
+				return cfg.SetNamespace(currentCtx.Name(), namespace)
 			}
 
 			ctxName := args[1]
@@ -69,8 +95,10 @@ or actual projects (git repository).`,
 
 func newProjectCloneCommand(ctx context.Context, cfg *config.Config) *cobra.Command {
 	var (
-		recursive bool
-		depth     int
+		recursive  bool
+		pin        bool
+		depth      int
+		filterOpts = new(filterOptions)
 
 		clone = &cobra.Command{
 			Use:          "clone [proj]",
@@ -81,7 +109,7 @@ func newProjectCloneCommand(ctx context.Context, cfg *config.Config) *cobra.Comm
 			Args:    cobra.RangeArgs(0, 1),
 			RunE: func(_ *cobra.Command, args []string) error {
 				// the project command doesn't really make sense if a concrete git repo.
-				cfg.PreferConfigContext = true
+				cfg.IgnoreLocalRepository()
 
 				cctx, err := cfg.GetCurrentContext()
 				if err != nil {
@@ -105,9 +133,14 @@ func newProjectCloneCommand(ctx context.Context, cfg *config.Config) *cobra.Comm
 					return errors.Wrapf(err, "could not get gitlab client")
 				}
 
+				filter, err := filterOpts.filter()
+				if err != nil {
+					return err
+				}
+
 				log.Infof("fetching projects...")
 
-				rootProj, err := client.GetProjects(ctx, false)
+				rootProj, err := client.GetProjects(ctx, gitlab.ProjectOptions{Filter: filter})
 				if err != nil {
 					return errors.Wrapf(err, "could not get namespace or project %s", namespace)
 				}
@@ -117,37 +150,111 @@ func newProjectCloneCommand(ctx context.Context, cfg *config.Config) *cobra.Comm
 					rootPath = rootProj.FullPath()
 				}
 
-				clone, err := gitlab.Clone(rootPath, skipRoot, cctx.Authentication())
+				if err := cctx.Instance().InstallGitTransport(); err != nil {
+					return errors.Wrapf(err, "could not setup git transport")
+				}
+
+				auth, err := cctx.Authentication()
+				if err != nil {
+					return err
+				}
+
+				clone, err := gitlab.Clone(rootPath, skipRoot, cctx.Instance().BaseURL(), auth)
 				if err != nil {
 					return errors.Wrapf(err, "could not setup clone environment")
 				}
 				// TODO: implement canceling the context by stopping the binary
-				return gitlab.WalkConcurrent(ctx, rootProj, clone)
+				if err := gitlab.WalkConcurrent(ctx, rootProj, clone); err != nil {
+					return err
+				}
+
+				if pin {
+					return pinClone(cfg, cctx, rootProj, rootPath)
+				}
+				return nil
 			},
 		}
 	)
 
 	clone.Flags().BoolVarP(&recursive, "recursive", "r", false, "list recursively")
 	clone.Flags().IntVarP(&depth, "depth", "d", -1, "depth to list recursively. -1 means infinite")
+	clone.Flags().BoolVar(&pin, "pin", true, "pin the cloned namespace to its folder with a "+config.PinFileName+" file")
+	filterOpts.addFlags(clone.Flags())
 	return clone
 }
 
+// pinClone pins the namespace of a cloned group to the folder that it
+// has been cloned into, so that commands run within it target the group.
+func pinClone(cfg *config.Config, cctx *config.Context, root gitlab.ProjectNode, rootPath gitlab.Namespace) error {
+	if _, ok := root.(*gitlab.Project); ok {
+		// projects are detected through their git repository
+		return nil
+	}
+
+	if _, ok := cfg.Instances[cctx.InstanceName]; !ok {
+		log.Debugf("not pinning clone, instance %v is not configured", cctx.InstanceName)
+		return nil
+	}
+
+	// the root has been cloned into the working directory if it is skipped.
+	dir := gitlab.CloneDir(rootPath, root)
+	if dir == "" {
+		dir = "."
+	}
+
+	err := config.WritePin(dir, &config.Pin{
+		Instance:  cctx.InstanceName,
+		Namespace: root.FullPath().String(),
+	})
+	return errors.Wrapf(err, "could not pin clone")
+}
+
 func newProjectListCommand(ctx context.Context, cfg *config.Config) *cobra.Command {
 	var (
 		depth           int
 		showDescription bool
 		showAll         bool
+		showStats       bool
+		output          string
+		flat            bool
+		sortField       string
+		reverse         bool
+		groupOrder      string
+		filterOpts      = new(filterOptions)
 
 		list = &cobra.Command{
 			Use:          listSub.Usage("[proj]"),
 			SilenceUsage: true,
 			Short:        "list projects in the current group", // TODO
-			Aliases:      listSub.abbr,
-			Args:         cobra.RangeArgs(0, 1),
+			Long: `list projects in the current group. By default, the projects are printed as a
+tree for humans. With --output, they are printed in a machine-readable format:
+json, yaml, csv or go-template=TEMPLATE, for example
+  gitlab-cli project list -d 0 --flat -o 'go-template={{range .}}{{.FullPath}}{{"\n"}}{{end}}'
+The nodes have the fields id, name, fullPath, type (project, group or user),
+archived, description, visibility, webURL, sshURL, httpURL, statistics (with
+--stats) and nodes, the nodes below them.
+Empty subgroups are listed too, unless the projects are filtered. Filters only
+apply to groups and users, not to a single project.
+With --flat, a list of all nodes is printed instead of the tree. CSV is always
+flat.
+
+Every level of the tree is sorted by --sort. Groups are sorted by the latest
+activity or creation date and by the total stars or size of the projects below
+them. Sorting by size requests the statistics of the projects, which needs at
+least the reporter role.
+
+With --stats, the repository size, LFS size, job artifacts size and commit count
+of every project are shown, and their totals for every group and user. They are
+counted for all projects below a group, even those deeper than --depth. As for
+sorting by size, this needs at least the reporter role, unknown statistics are
+shown as "-". Totals that leave out projects with unknown statistics are shown
+as lower bounds, e.g. "≥ 1.5 KiB", and have "partial" set.`,
+			Aliases: listSub.abbr,
+			Args:    cobra.RangeArgs(0, 1),
 			RunE: func(_ *cobra.Command, args []string) error {
 				// TODO: move this to PersistentPreRun in Project command. Couldn't get it to work.
 				// the project command doesn't really make sense if a concrete git repo.
-				cfg.PreferConfigContext = true
+				cfg.IgnoreLocalRepository()
 
 				cctx, err := cfg.GetCurrentContext()
 				if err != nil {
@@ -168,28 +275,210 @@ func newProjectListCommand(ctx context.Context, cfg *config.Config) *cobra.Comma
 
 				log.Infof("fetching projects...")
 
-				rootProj, err := client.GetProjects(ctx, showAll)
+				filter, err := filterOpts.filter()
+				if err != nil {
+					return err
+				}
+
+				sortOpts := gitlab.SortOptions{Reverse: reverse}
+				if sortOpts.Field, err = gitlab.ParseSortField(sortField); err != nil {
+					return err
+				}
+				if sortOpts.Groups, err = gitlab.ParseGroupOrder(groupOrder); err != nil {
+					return err
+				}
+
+				rootProj, err := client.GetProjects(ctx, gitlab.ProjectOptions{
+					IncludeArchived: showAll,
+					Filter:          filter,
+					Statistics:      showStats || sortOpts.NeedsStatistics(),
+				})
 				if err != nil {
 					return errors.Wrapf(err, "could not get namespace or project %s", namespace)
 				}
 
-				fmt.Printf("%v", gitlab.PrintProject(rootProj, gitlab.PrintOptions{
+				gitlab.Sort(rootProj, sortOpts)
+
+				printOpts := gitlab.PrintOptions{
 					PrintArchived:    showAll,
 					PrintDescription: showDescription,
+					PrintStatistics:  showStats,
 					Depth:            depth,
-				}))
+				}
+
+				if output != "" {
+					return gitlab.EncodeProject(os.Stdout, rootProj, gitlab.EncodeOptions{
+						PrintOptions: printOpts,
+						Format:       output,
+						Flat:         flat,
+					})
+				}
+
+				fmt.Printf("%v", gitlab.PrintProject(rootProj, printOpts))
 				return nil
 			},
 		}
 	)
 
 	list.Flags().IntVarP(&depth, "depth", "d", 1, "depth to list recursively. 0 means infinite")
-	list.Flags().BoolVar(&showDescription, "desc", false, "show description of projects too")
+	list.Flags().BoolVar(&showDescription, "desc", false, "show description of projects and groups too")
 	list.Flags().BoolVarP(&showAll, "all", "a", false, "show all projects, including archived ones")
+	list.Flags().BoolVar(&showStats, "stats", false, "show the storage statistics and commit count of projects, with totals for groups")
+	list.Flags().StringVarP(&output, "output", "o", "", "output format: json, yaml, csv or go-template=TEMPLATE. defaults to a tree")
+	list.Flags().BoolVar(&flat, "flat", false, "print a list of all projects and groups instead of the tree, with --output")
+	list.Flags().StringVar(&sortField, "sort", string(gitlab.SortByName), "sort by name, path, last-activity, created, stars or size")
+	list.Flags().BoolVar(&reverse, "reverse", false, "reverse the sort order")
+	list.Flags().StringVar(&groupOrder, "groups", string(gitlab.GroupsMixed), "list groups first, last or mixed with the projects")
+	filterOpts.addFlags(list.Flags())
 
 	return list
 }
 
+// pickNamespace lets the user pick a group or project below the namespace
+// of the context, or one of the user's namespaces if the context has none.
+// The returned path is absolute.
+func pickNamespace(ctx context.Context, cctx *config.Context) (string, error) {
+	if !picker.IsInteractive() {
+		return "", errors.New("no project given, and no terminal to pick one")
+	}
+
+	client, err := cctx.GitlabClient()
+	if err != nil {
+		return "", errors.Wrapf(err, "could not get gitlab client")
+	}
+
+	var candidates []string
+	if cctx.Namespace == "" {
+		log.Infof("fetching namespaces...")
+		if candidates, err = client.ListNamespaces(ctx); err != nil {
+			return "", errors.Wrapf(err, "could not list namespaces")
+		}
+	} else {
+		log.Infof("fetching projects...")
+		root, err := client.GetProjects(ctx, gitlab.ProjectOptions{})
+		if err != nil {
+			return "", errors.Wrapf(err, "could not get namespace or project %s", cctx.Namespace)
+		}
+
+		err = gitlab.Walk(root, func(p gitlab.ProjectNode) error {
+			candidates = append(candidates, p.FullPath().String())
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	picked, err := picker.Pick("namespace:", candidates)
+	if err != nil {
+		return "", err
+	}
+	return "/" + picked, nil
+}
+
+// filterOptions are the flags that select the projects of a listing.
+type filterOptions struct {
+	match, matchRegexp string
+	visibility         string
+	topics             []string
+	language           string
+	minAccessLevel     string
+	activeSince        string
+
+	membership, owned, starred bool
+}
+
+func (o *filterOptions) addFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&o.match, "match", "", "only projects whose name, path or full path match this glob, e.g. 'api-*'")
+	flags.StringVar(&o.matchRegexp, "match-regexp", "", "only projects whose full path matches this regular expression")
+	flags.StringVar(&o.visibility, "visibility", "", "only projects with this visibility: private, internal or public")
+	flags.StringSliceVar(&o.topics, "topic", nil, "only projects with all of these topics")
+	flags.StringVar(&o.language, "language", "", "only projects that contain this programming language")
+	flags.StringVar(&o.minAccessLevel, "min-access-level", "", "only projects that you have at least this access to: guest, reporter, developer, maintainer or owner")
+	flags.StringVar(&o.activeSince, "active-since", "", "only projects with activity since this date (2006-01-02) or duration, e.g. 720h or 30d")
+	flags.BoolVar(&o.membership, "membership", false, "only projects that you are a member of")
+	flags.BoolVar(&o.owned, "owned", false, "only projects that you own")
+	flags.BoolVar(&o.starred, "starred", false, "only projects that you starred")
+}
+
+var accessLevels = map[string]gl.AccessLevelValue{
+	"guest":      gl.GuestPermissions,
+	"reporter":   gl.ReporterPermissions,
+	"developer":  gl.DeveloperPermissions,
+	"maintainer": gl.MaintainerPermissions,
+	"owner":      gl.OwnerPermissions,
+}
+
+func (o *filterOptions) filter() (gitlab.Filter, error) {
+	f := gitlab.Filter{
+		Glob:       o.match,
+		Topics:     o.topics,
+		Language:   o.language,
+		Membership: o.membership,
+		Owned:      o.owned,
+		Starred:    o.starred,
+	}
+
+	if o.match != "" {
+		if _, err := path.Match(o.match, ""); err != nil {
+			return f, errors.Wrapf(err, "invalid --match %q", o.match)
+		}
+	}
+
+	if o.matchRegexp != "" {
+		re, err := regexp.Compile(o.matchRegexp)
+		if err != nil {
+			return f, errors.Wrapf(err, "invalid --match-regexp %q", o.matchRegexp)
+		}
+		f.Regexp = re
+	}
+
+	switch v := gl.VisibilityValue(o.visibility); v {
+	case "", gl.PrivateVisibility, gl.InternalVisibility, gl.PublicVisibility:
+		f.Visibility = v
+	default:
+		return f, errors.Errorf("invalid --visibility %q, must be private, internal or public", o.visibility)
+	}
+
+	if o.minAccessLevel != "" {
+		level, ok := accessLevels[strings.ToLower(o.minAccessLevel)]
+		if !ok {
+			return f, errors.Errorf("invalid --min-access-level %q, must be guest, reporter, developer, maintainer or owner", o.minAccessLevel)
+		}
+		f.MinAccessLevel = level
+	}
+
+	if o.activeSince != "" {
+		since, err := parseSince(o.activeSince, time.Now())
+		if err != nil {
+			return f, err
+		}
+		f.ActiveSince = since
+	}
+
+	return f, nil
+}
+
+// parseSince parses a date, or a duration before now. Besides the units
+// of time.ParseDuration, durations may be given in days, e.g. "30d".
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		if n, err := strconv.Atoi(days); err == nil {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid date or duration %q", s)
+	}
+	return now.Add(-d), nil
+}
+
 // getAbsoluteGroupPath checks if the given newGroup is a relative or
 // absolute path. If it is an absolute path, this is returned. If it is
 // relative, it is appended to the currentGroup.
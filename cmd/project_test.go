@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tommyknows/gitlab-cli/api/config"
+	"github.com/tommyknows/gitlab-cli/pkg/log"
+)
+
+func TestProjectListOutputOnlyOnStdout(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/namespaces/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "404 Namespace Not Found"}`, http.StatusNotFound)
+	})
+	mux.HandleFunc("/api/v4/projects/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 3, "name": "cli", "path": "cli", "path_with_namespace": "platform/cli", "namespace": {"full_path": "platform"}}`)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "gitlab-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	env := map[string]string{
+		config.HostEnv:        srv.URL,
+		config.GitlabTokenEnv: "token",
+		config.ContextEnv:     "",
+		config.ConfigEnv:      "",
+	}
+	for k, v := range env {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Setenv(k, v)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	// the logger has to be set up after stdout has been replaced, as
+	// it would otherwise not notice writes to stdout.
+	log.Setup("debug")
+
+	cmd := gitlabCLI()
+	cmd.SetArgs([]string{"--config", filepath.Join(dir, "config.yml"), "project", "list", "/platform/cli", "-o", "json"})
+	err = cmd.Execute()
+	w.Close()
+	if err != nil {
+		t.Fatalf("could not list projects: %v", err)
+	}
+
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var node map[string]interface{}
+	if err := json.Unmarshal(out, &node); err != nil {
+		t.Fatalf("stdout is not a JSON document: %v\n%s", err, out)
+	}
+
+	if node["fullPath"] != "platform/cli" {
+		t.Errorf("full path not correct. expected=%v, got=%v", "platform/cli", node["fullPath"])
+	}
+}
+
+func TestProjectClonePinUppercaseGroup(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/namespaces/Platform", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 1, "full_path": "Platform", "kind": "group"}`)
+	})
+	mux.HandleFunc("/api/v4/groups/Platform", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 1, "name": "Platform", "path": "Platform", "full_path": "Platform"}`)
+	})
+	mux.HandleFunc("/api/v4/groups/Platform/descendant_groups", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": 2, "name": "Tools", "path": "Tools", "full_path": "Platform/Tools"}]`)
+	})
+	mux.HandleFunc("/api/v4/groups/Platform/projects", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "gitlab-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, env := range []string{config.HostEnv, config.GitlabTokenEnv, config.ContextEnv, config.ConfigEnv} {
+		defer os.Setenv(env, os.Getenv(env))
+		os.Unsetenv(env)
+	}
+
+	cfgFile := filepath.Join(dir, "config.yml")
+	cfg := fmt.Sprintf(`instances:
+  work:
+    url: %v
+    authentication:
+      type: token
+      token: token
+contexts:
+  work:
+    instance: work
+currentContext: work
+`, srv.URL)
+	if err := ioutil.WriteFile(cfgFile, []byte(cfg), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := gitlabCLI()
+	cmd.SetArgs([]string{"--config", cfgFile, "project", "clone", "Platform"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("could not clone group: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "platform", "tools")); err != nil {
+		t.Errorf("folder of subgroup has not been created: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "platform", config.PinFileName)); err != nil {
+		t.Errorf("clone has not been pinned: %v", err)
+	}
+}
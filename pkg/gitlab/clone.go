@@ -2,7 +2,9 @@ package gitlab
 
 import (
 	"context"
+	"net/url"
 	"os"
+	"path"
 
 	"github.com/go-git/go-git/plumbing/transport"
 	"github.com/go-git/go-git/v5"
@@ -14,56 +16,78 @@ import (
 // Clone the ProjectNodes into the current directory, mirroring the upstream structure.
 // The rootGroup is needed in order to strip away the leading path from the full Project
 // Paths. If skipRoot is true and the root namespace matches the node's full path, the
-// node will be skipped.
-func Clone(root Namespace, skipRoot bool, auth transport.AuthMethod) (ContextVisitor, error) {
+// node will be skipped. If baseURL is set, projects are cloned from that instance URL
+// instead of the HTTP URL that is advertised by the API.
+func Clone(root Namespace, skipRoot bool, baseURL *url.URL, auth transport.AuthMethod) (ContextVisitor, error) {
 	return func(ctx context.Context, n ProjectNode) error {
 		switch n := n.(type) {
 		case *Project:
-			path := Namespace(n.FullPath()).relative(root)
+			dir := CloneDir(root, n)
 
-			repo, err := git.PlainOpen(path.String())
+			repo, err := git.PlainOpen(dir)
 			if repo != nil && err != git.ErrRepositoryNotExists {
-				log.Debugf("Pulling %s in %s", n.Name(), path)
-				if err := pull(repo, n.gp, auth); err != nil {
-					return errors.Wrapf(err, "could not pull existing repo at %v", path.String())
+				log.Debugf("Pulling %s in %s", n.Name(), dir)
+				if err := pull(repo, n.gp, baseURL, auth); err != nil {
+					return errors.Wrapf(err, "could not pull existing repo at %v", dir)
 				}
 				return nil
 			}
 
-			log.Debugf("cloning %s to ./%s", n.Name(), path)
+			log.Debugf("cloning %s to ./%s", n.Name(), dir)
 
-			_, err = git.PlainCloneContext(ctx, n.FullPath().relative(root).String(), false, &git.CloneOptions{
-				URL:  n.gp.HTTPURLToRepo,
+			_, err = git.PlainCloneContext(ctx, dir, false, &git.CloneOptions{
+				URL:  cloneURL(baseURL, n.gp),
 				Auth: auth,
 			})
 			if err != nil {
 				return errors.Wrapf(err, "could not clone project %v", n.Name())
 			}
 
-			log.Debugf("cloned %v to ./%v", n.Name(), Namespace(n.FullPath()).relative(root))
+			log.Debugf("cloned %v to ./%v", n.Name(), dir)
 
 		case *Group:
 			if skipRoot && n.FullPath() == root {
 				break
 			}
 
-			log.Debugf("creating folder %v for group %v\n", n.FullPath().relative(root), n.Name())
-			if err := os.MkdirAll(n.FullPath().relative(root).String(), 0700); err != nil {
+			dir := CloneDir(root, n)
+			log.Debugf("creating folder %v for group %v\n", dir, n.Name())
+			if err := os.MkdirAll(dir, 0700); err != nil {
 				return errors.Wrapf(err, "could not create folder for group %v", n.Name())
 			}
-			log.Debugf("created folder %v for group %v", n.FullPath().relative(root), n.Name())
+			log.Debugf("created folder %v for group %v", dir, n.Name())
 		}
 		return nil
 	}, nil
 }
 
-func pull(repo *git.Repository, proj *gitlab.Project, auth transport.AuthMethod) error {
+// CloneDir returns the folder that Clone creates for the node, relative
+// to the working directory. Like all namespaces, it is lowercase.
+func CloneDir(root Namespace, n ProjectNode) string {
+	return n.FullPath().relative(root).String()
+}
+
+// cloneURL returns the HTTP URL to clone the project from. The URL advertised
+// by Gitlab depends on the instance's external URL setting, which may not
+// match the URL that we're using to reach the instance (e.g. a different
+// port or scheme behind a proxy).
+func cloneURL(baseURL *url.URL, proj *gitlab.Project) string {
+	if baseURL == nil {
+		return proj.HTTPURLToRepo
+	}
+
+	u := *baseURL
+	u.Path = path.Join(u.Path, proj.PathWithNamespace) + ".git"
+	return u.String()
+}
+
+func pull(repo *git.Repository, proj *gitlab.Project, baseURL *url.URL, auth transport.AuthMethod) error {
 	w, err := repo.Worktree()
 	if err != nil {
 		return errors.Wrapf(err, "could not get worktree from git repository")
 	}
 
-	remote, err := determineRemote(repo, proj)
+	remote, err := determineRemote(repo, proj, baseURL)
 	if err != nil {
 		return errors.Wrapf(err, "could not get remote")
 	}
@@ -78,7 +102,7 @@ func pull(repo *git.Repository, proj *gitlab.Project, auth transport.AuthMethod)
 	return nil
 }
 
-func determineRemote(repo *git.Repository, proj *gitlab.Project) (string, error) {
+func determineRemote(repo *git.Repository, proj *gitlab.Project, baseURL *url.URL) (string, error) {
 	remotes, err := repo.Remotes()
 	if err != nil {
 		return "", errors.Wrapf(err, "could not get remotes")
@@ -86,7 +110,7 @@ func determineRemote(repo *git.Repository, proj *gitlab.Project) (string, error)
 
 	for _, remote := range remotes {
 		for _, url := range remote.Config().URLs {
-			if url == proj.SSHURLToRepo || url == proj.HTTPURLToRepo {
+			if url == proj.SSHURLToRepo || url == proj.HTTPURLToRepo || url == cloneURL(baseURL, proj) {
 				return remote.Config().Name, nil
 			}
 		}
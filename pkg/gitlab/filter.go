@@ -0,0 +1,194 @@
+package gitlab
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tommyknows/gitlab-cli/pkg/log"
+	gl "github.com/xanzy/go-gitlab"
+)
+
+// Filter selects the projects of a listing. The zero value selects all
+// projects. Filters are passed to the API where Gitlab supports them,
+// the others are applied when building the tree.
+type Filter struct {
+	// Glob matches the name, the path or the full path of projects,
+	// see path.Match. Matching is case-insensitive.
+	Glob string
+	// Regexp matches the full path of projects.
+	Regexp *regexp.Regexp
+
+	Visibility gl.VisibilityValue
+	// Topics that a project must all have.
+	Topics []string
+	// Language is a programming language of the repository.
+	Language string
+	// MinAccessLevel is the minimum access level of the user.
+	MinAccessLevel gl.AccessLevelValue
+
+	// Membership selects projects that the user is a member of.
+	Membership bool
+	// Owned selects projects that are owned by the user.
+	Owned bool
+	// Starred selects projects that have been starred by the user.
+	Starred bool
+	// ActiveSince selects projects with activity since then.
+	ActiveSince time.Time
+}
+
+// IsZero returns true if the filter selects all projects.
+func (f *Filter) IsZero() bool {
+	return f.Glob == "" && f.Regexp == nil && f.Visibility == "" && len(f.Topics) == 0 &&
+		f.Language == "" && f.MinAccessLevel == 0 && !f.Membership && !f.Owned && !f.Starred &&
+		f.ActiveSince.IsZero()
+}
+
+// needsProjectsAPI returns true if the filter can only be applied by
+// the projects API, as the group projects API does not support it.
+func (f *Filter) needsProjectsAPI() bool {
+	return f.Membership || f.MinAccessLevel != 0
+}
+
+// listProjectsOptions returns the options for the projects API, with
+// all filters that it supports.
+func (f *Filter) listProjectsOptions(archived bool) *gl.ListProjectsOptions {
+	opts := &gl.ListProjectsOptions{
+		Archived: gl.Bool(archived),
+		ListOptions: gl.ListOptions{
+			Page:    1,
+			PerPage: 100, // this is the max value from gitlab
+		},
+	}
+
+	if f.Visibility != "" {
+		opts.Visibility = gl.Visibility(f.Visibility)
+	}
+	if f.Language != "" {
+		opts.WithProgrammingLanguage = gl.String(f.Language)
+	}
+	if f.MinAccessLevel != 0 {
+		opts.MinAccessLevel = gl.AccessLevel(f.MinAccessLevel)
+	}
+	if f.Membership {
+		opts.Membership = gl.Bool(true)
+	}
+	if f.Owned {
+		opts.Owned = gl.Bool(true)
+	}
+	if f.Starred {
+		opts.Starred = gl.Bool(true)
+	}
+	if !f.ActiveSince.IsZero() {
+		opts.LastActivityAfter = gl.Time(f.ActiveSince)
+	}
+	return opts
+}
+
+// listGroupProjectsOptions returns the options for the group projects
+// API, with all filters that it supports.
+func (f *Filter) listGroupProjectsOptions(archived bool) *gl.ListGroupProjectsOptions {
+	opts := &gl.ListGroupProjectsOptions{
+		IncludeSubgroups: gl.Bool(true),
+		Archived:         gl.Bool(archived),
+		ListOptions: gl.ListOptions{
+			Page:    1,
+			PerPage: 100, // this is the max value from gitlab
+		},
+	}
+
+	if f.Visibility != "" {
+		opts.Visibility = gl.Visibility(f.Visibility)
+	}
+	if f.Owned {
+		opts.Owned = gl.Bool(true)
+	}
+	if f.Starred {
+		opts.Starred = gl.Bool(true)
+	}
+	return opts
+}
+
+// matches returns true if the project passes the filters that can be
+// checked on the project itself. The others are up to the API.
+func (f *Filter) matches(p *gl.Project) bool {
+	if f.Glob != "" && !f.matchesGlob(p) {
+		return false
+	}
+
+	if f.Regexp != nil && !f.Regexp.MatchString(p.PathWithNamespace) {
+		return false
+	}
+
+	if f.Visibility != "" && p.Visibility != f.Visibility {
+		return false
+	}
+
+	for _, topic := range f.Topics {
+		if !containsFold(p.TagList, topic) {
+			return false
+		}
+	}
+
+	if !f.ActiveSince.IsZero() && (p.LastActivityAt == nil || p.LastActivityAt.Before(f.ActiveSince)) {
+		return false
+	}
+
+	return true
+}
+
+// filter returns the projects that match the filter.
+func (f *Filter) filter(projects []*gl.Project) []*gl.Project {
+	var filtered []*gl.Project
+	for _, p := range projects {
+		if f.matches(p) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+func (f *Filter) matchesGlob(p *gl.Project) bool {
+	pattern := strings.ToLower(f.Glob)
+	for _, s := range []string{p.Name, p.Path, p.PathWithNamespace} {
+		if ok, _ := path.Match(pattern, strings.ToLower(s)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// filterLanguage keeps the projects whose repository contains the
+// language of the filter. As only the projects API can filter by
+// language, the languages of every project are fetched.
+func (c *Client) filterLanguage(ctx context.Context, projects []*gl.Project, language string) ([]*gl.Project, error) {
+	log.Debugf("fetching the languages of %v projects", len(projects))
+
+	var filtered []*gl.Project
+	for _, p := range projects {
+		languages, _, err := c.c.Projects.GetProjectLanguages(p.ID, gl.WithContext(ctx))
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not get languages of project %v", p.PathWithNamespace)
+		}
+
+		for l := range *languages {
+			if strings.EqualFold(l, language) {
+				filtered = append(filtered, p)
+				break
+			}
+		}
+	}
+	return filtered, nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, e := range list {
+		if strings.EqualFold(e, s) {
+			return true
+		}
+	}
+	return false
+}
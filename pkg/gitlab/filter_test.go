@@ -0,0 +1,200 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	gl "github.com/xanzy/go-gitlab"
+)
+
+func TestFilterMatches(t *testing.T) {
+	lastWeek := time.Now().AddDate(0, 0, -7)
+	p := &gl.Project{
+		Name:              "API Gateway",
+		Path:              "api-gateway",
+		PathWithNamespace: "platform/backend/api-gateway",
+		Visibility:        gl.InternalVisibility,
+		TagList:           []string{"Go", "kubernetes"},
+		LastActivityAt:    &lastWeek,
+	}
+
+	tests := []struct {
+		filter  Filter
+		matches bool
+	}{
+		{Filter{}, true},
+		{Filter{Glob: "api-*"}, true},
+		{Filter{Glob: "API *"}, true},
+		{Filter{Glob: "platform/*/api-*"}, true},
+		{Filter{Glob: "web-*"}, false},
+		{Filter{Regexp: regexp.MustCompile(`backend/.*gate`)}, true},
+		{Filter{Regexp: regexp.MustCompile(`^backend`)}, false},
+		{Filter{Visibility: gl.InternalVisibility}, true},
+		{Filter{Visibility: gl.PublicVisibility}, false},
+		{Filter{Topics: []string{"go", "Kubernetes"}}, true},
+		{Filter{Topics: []string{"go", "rust"}}, false},
+		{Filter{ActiveSince: time.Now().AddDate(0, -1, 0)}, true},
+		{Filter{ActiveSince: time.Now().AddDate(0, 0, -1)}, false},
+	}
+
+	for _, tt := range tests {
+		if m := tt.filter.matches(p); m != tt.matches {
+			t.Errorf("match of filter %+v not correct. expected=%v, got=%v", tt.filter, tt.matches, m)
+		}
+	}
+}
+
+func TestPruneEmptyGroups(t *testing.T) {
+	root := newGroup(&gl.Group{Name: "root", FullPath: "root"})
+	empty := &Group{name: "empty", namespace: "root", fullPath: "root/empty"}
+	empty.addNodes(&Group{name: "nested", namespace: "root/empty", fullPath: "root/empty/nested"})
+	root.addNodes(empty)
+
+	filter := Filter{Glob: "keep"}
+	addSubProjects(root, filter.filter([]*gl.Project{
+		{Name: "keep", PathWithNamespace: "root/sub/keep", Namespace: &gl.ProjectNamespace{FullPath: "root/sub"}},
+		{Name: "drop", PathWithNamespace: "root/other/drop", Namespace: &gl.ProjectNamespace{FullPath: "root/other"}},
+	}))
+
+	pruneEmptyGroups(root)
+
+	var paths []string
+	_ = Walk(root, func(p ProjectNode) error {
+		paths = append(paths, p.FullPath().String())
+		return nil
+	})
+
+	expected := []string{"root", "root/sub", "root/sub/keep"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("tree not correct. expected=%v, got=%v", expected, paths)
+	}
+}
+
+func TestGetProjectsFilter(t *testing.T) {
+	var queries, languages []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/namespaces/platform", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 1, "full_path": "platform", "kind": "group"}`)
+	})
+	mux.HandleFunc("/api/v4/groups/platform", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 1, "name": "platform", "full_path": "platform"}`)
+	})
+	mux.HandleFunc("/api/v4/groups/platform/descendant_groups", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[
+			{"id": 5, "name": "Backend", "full_path": "platform/backend"},
+			{"id": 6, "name": "Frontend", "full_path": "platform/frontend"},
+			{"id": 7, "name": "Empty", "full_path": "platform/empty"}
+		]`)
+	})
+	mux.HandleFunc("/api/v4/groups/platform/projects", func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query().Encode())
+		fmt.Fprint(w, `[
+			{"id": 2, "name": "api", "path_with_namespace": "platform/backend/api", "namespace": {"full_path": "platform/backend"}},
+			{"id": 3, "name": "web", "path_with_namespace": "platform/frontend/web", "namespace": {"full_path": "platform/frontend"}}
+		]`)
+	})
+	mux.HandleFunc("/api/v4/projects", func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query().Encode())
+		fmt.Fprint(w, `[
+			{"id": 2, "name": "api", "path_with_namespace": "platform/backend/api", "namespace": {"full_path": "platform/backend"}},
+			{"id": 4, "name": "other", "path_with_namespace": "other/project", "namespace": {"full_path": "other"}}
+		]`)
+	})
+	mux.HandleFunc("/api/v4/projects/2/languages", func(w http.ResponseWriter, r *http.Request) {
+		languages = append(languages, "platform/backend/api")
+		fmt.Fprint(w, `{"Go": 90.5, "Shell": 9.5}`)
+	})
+	mux.HandleFunc("/api/v4/projects/3/languages", func(w http.ResponseWriter, r *http.Request) {
+		languages = append(languages, "platform/frontend/web")
+		fmt.Fprint(w, `{"TypeScript": 100}`)
+	})
+
+	cl := newTestClient(t, mux)
+
+	tests := []struct {
+		filter    Filter
+		nodes     []string
+		query     string
+		languages []string
+	}{
+		{
+			// without a filter, empty groups are kept.
+			filter: Filter{},
+			nodes:  []string{"platform", "platform/backend", "platform/backend/api", "platform/empty", "platform/frontend", "platform/frontend/web"},
+			query:  "archived=false&include_subgroups=true&page=1&per_page=100",
+		},
+		{
+			// groups without matching projects are pruned.
+			filter:    Filter{Language: "go", Owned: true},
+			nodes:     []string{"platform", "platform/backend", "platform/backend/api"},
+			query:     "archived=false&include_subgroups=true&owned=true&page=1&per_page=100",
+			languages: []string{"platform/backend/api", "platform/frontend/web"},
+		},
+		{
+			// the languages are only fetched for projects
+			// that pass the other filters.
+			filter:    Filter{Language: "go", Glob: "web"},
+			nodes:     []string{"platform"},
+			query:     "archived=false&include_subgroups=true&page=1&per_page=100",
+			languages: []string{"platform/frontend/web"},
+		},
+		{
+			// only the projects API filters by membership, projects
+			// outside of the group must be dropped.
+			filter: Filter{Membership: true, Language: "Go"},
+			nodes:  []string{"platform", "platform/backend", "platform/backend/api"},
+			query:  "archived=false&membership=true&page=1&per_page=100&with_programming_language=Go",
+		},
+	}
+
+	for _, tt := range tests {
+		queries, languages = nil, nil
+
+		root, err := New(cl, "platform").GetProjects(context.Background(), ProjectOptions{Filter: tt.filter})
+		if err != nil {
+			t.Fatalf("could not get projects: %v", err)
+		}
+
+		var nodes []string
+		_ = Walk(root, func(p ProjectNode) error {
+			nodes = append(nodes, p.FullPath().String())
+			return nil
+		})
+
+		if !reflect.DeepEqual(nodes, tt.nodes) {
+			t.Errorf("tree not correct for filter %+v. expected=%v, got=%v", tt.filter, tt.nodes, nodes)
+		}
+
+		if len(queries) != 1 || queries[0] != tt.query {
+			t.Errorf("query not correct for filter %+v. expected=%v, got=%v", tt.filter, tt.query, queries)
+		}
+
+		if !reflect.DeepEqual(languages, tt.languages) {
+			t.Errorf("languages fetched not correct for filter %+v. expected=%v, got=%v", tt.filter, tt.languages, languages)
+		}
+	}
+}
+
+func TestGetProjectsFilterProject(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/namespaces/", http.NotFound)
+	mux.HandleFunc("/api/v4/projects/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 2, "name": "api", "path_with_namespace": "platform/backend/api", "namespace": {"full_path": "platform/backend"}}`)
+	})
+
+	cl := newTestClient(t, mux)
+
+	if _, err := New(cl, "platform/backend/api").GetProjects(context.Background(), ProjectOptions{}); err != nil {
+		t.Errorf("could not get project: %v", err)
+	}
+
+	_, err := New(cl, "platform/backend/api").GetProjects(context.Background(), ProjectOptions{Filter: Filter{Glob: "web"}})
+	if err != ErrFilterProject {
+		t.Errorf("error not correct. expected=%v, got=%v", ErrFilterProject, err)
+	}
+}
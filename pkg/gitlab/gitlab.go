@@ -7,6 +7,7 @@ package gitlab
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"path"
@@ -39,88 +40,126 @@ func extractNamespace(fullPath string) Namespace {
 	return Namespace(fullPath[:lastSlash])
 }
 
+// ErrNotFound is returned if the namespace of the client does
+// not exist, neither as group or user nor as project.
+var ErrNotFound = errors.New("no such namespace or project")
+
+// ErrFilterProject is returned if projects are filtered, but the
+// namespace of the client is a single project.
+var ErrFilterProject = errors.New("filters only apply to groups and users, not to a single project")
+
+// ProjectOptions configure GetProjects.
+type ProjectOptions struct {
+	IncludeArchived bool
+	Filter          Filter
+	// Statistics fetches the statistics of the projects too, e.g. the
+	// storage size. Only users with at least the reporter role can
+	// see them.
+	Statistics bool
+}
+
 // GetProjects gets the project of the set namespace, returning the root of a Project-tree.
-func (c *Client) GetProjects(ctx context.Context, includeArchived bool) (root ProjectNode, err error) {
-	ns, resp, err := c.c.Namespaces.GetNamespace(url.QueryEscape(c.namespace), gl.WithContext(ctx))
-	if err == nil {
-		switch ns.Kind {
-		case "group":
-			return c.getGroup(ctx, c.namespace, includeArchived)
-		case "user":
-			return c.getUser(ctx, c.namespace, includeArchived)
-		default:
-			return nil, errors.New("unknown kind: " + ns.Kind)
-		}
+// If the namespace is a single project, ErrFilterProject is returned for non-zero filters.
+func (c *Client) GetProjects(ctx context.Context, opts ProjectOptions) (root ProjectNode, err error) {
+	kind, p, err := c.lookupNamespace(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	// some connection error - no internet connection for example.
-	if resp == nil && err != nil {
-		return nil, err
+	switch kind {
+	case GroupKind:
+		return c.getGroup(ctx, c.namespace, opts)
+	case UserKind:
+		return c.getUser(ctx, c.namespace, opts)
+	default:
+		if !opts.Filter.IsZero() {
+			return nil, ErrFilterProject
+		}
+		return newProject(p), nil
 	}
+}
 
-	// it could be that the namespace really not exists.
-	// it could also be that it is a project. In this case,
-	// (and we don't really know), we try getting the project.
-	if resp != nil && resp.StatusCode != http.StatusNotFound {
+// listAll fetches all pages of a project listing.
+func listAll(ctx context.Context, opts *gl.ListOptions, list func() ([]*gl.Project, *gl.Response, error)) ([]*gl.Project, error) {
+	var projects []*gl.Project
+	err := paginate(ctx, opts, func() (*gl.Response, error) {
+		p, resp, err := list()
+		projects = append(projects, p...)
+		return resp, err
+	})
+	if err != nil {
 		return nil, err
 	}
+	return projects, nil
+}
 
-	tr := true
-	p, resp, err := c.c.Projects.GetProject(c.namespace, &gl.GetProjectOptions{
-		Statistics: &tr,
-	}, gl.WithContext(ctx))
-	if err == nil {
-		return newProject(p), nil
-	}
+// paginate calls page until all pages of a listing have been fetched.
+func paginate(ctx context.Context, opts *gl.ListOptions, page func() (*gl.Response, error)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return context.Canceled
+		default:
+		}
 
-	if resp.StatusCode != http.StatusNotFound {
-		return nil, err
+		resp, err := page()
+		if err != nil {
+			return err
+		}
+
+		// Exit the loop when we've seen all pages.
+		if resp.CurrentPage >= resp.TotalPages {
+			log.Debugf("got all results from the API")
+			return nil
+		}
+
+		// update page number to fetch next page
+		log.Debugf("getting next page from the API")
+		opts.Page = resp.NextPage
 	}
-	// the error message is a lengthy string that includes the
-	// URL of the request. We don't need it, as we know exactly
-	// what it is, so we just create a custom error
-	return nil, errors.New("no such namespace or project")
 }
 
-// getUser and getGroup have an extreme amount of duplicated code. Yet, I cannot find a simple
-// solution to unify them without adding a ton of abstraction.
-func (c *Client) getUser(ctx context.Context, user string, includeArchived bool) (root ProjectNode, err error) {
-	getProjects := func(archived bool) ([]*gl.Project, error) {
-		var projects []*gl.Project
-
-		opts := &gl.ListProjectsOptions{
-			Archived: &archived,
-			ListOptions: gl.ListOptions{
-				Page:    1,
-				PerPage: 100, // this is the max value from gitlab
-			},
+// addProjects fetches the projects, once without and, if they are
+// included, once with the archived ones, and adds them to the root.
+func (c *Client) addProjects(ctx context.Context, root noder, opts ProjectOptions, languageFiltered bool, getProjects func(archived bool) ([]*gl.Project, error)) error {
+	archived := []bool{false}
+	if opts.IncludeArchived {
+		archived = append(archived, true)
+	}
+
+	for _, a := range archived {
+		projects, err := getProjects(a)
+		if err != nil {
+			return err
 		}
-		for {
-			select {
-			case <-ctx.Done():
-				return nil, context.Canceled
-			default:
-			}
 
-			p, resp, err := c.c.Projects.ListUserProjects(user, opts, gl.WithContext(ctx))
-			if err != nil {
-				return nil, err
+		// the languages are fetched per project, so only
+		// for those that pass the other filters.
+		projects = opts.Filter.filter(projects)
+		if opts.Filter.Language != "" && !languageFiltered {
+			if projects, err = c.filterLanguage(ctx, projects, opts.Filter.Language); err != nil {
+				return err
 			}
+		}
 
-			projects = append(projects, p...)
+		addSubProjects(root, projects)
+	}
 
-			// Exit the loop when we've seen all pages.
-			if resp.CurrentPage >= resp.TotalPages {
-				log.Debugf("got all results from the API")
-				break
-			}
+	if !opts.Filter.IsZero() {
+		pruneEmptyGroups(root)
+	}
+	return nil
+}
 
-			// update page number to fetch next page
-			log.Debugf("getting next page from the API")
-			opts.Page = resp.NextPage
+func (c *Client) getUser(ctx context.Context, user string, opts ProjectOptions) (root ProjectNode, err error) {
+	getProjects := func(archived bool) ([]*gl.Project, error) {
+		lopts := opts.Filter.listProjectsOptions(archived)
+		if opts.Statistics {
+			lopts.Statistics = gl.Bool(true)
 		}
-
-		return projects, nil
+		return listAll(ctx, &lopts.ListOptions, func() ([]*gl.Project, *gl.Response, error) {
+			return c.c.Projects.ListUserProjects(user, lopts, gl.WithContext(ctx))
+		})
 	}
 
 	u, _, err := c.c.Users.ListUsers(&gl.ListUsersOptions{Username: &user})
@@ -139,62 +178,45 @@ func (c *Client) getUser(ctx context.Context, user string, includeArchived bool)
 
 	usr := newUser(u[0])
 
-	projects, err := getProjects(false)
-	if err != nil {
+	if err := c.addProjects(ctx, usr, opts, true, getProjects); err != nil {
 		return nil, err
 	}
-	addSubProjects(usr, projects)
-
-	if includeArchived {
-		projects, err := getProjects(true)
-		if err != nil {
-			return nil, err
-		}
-
-		addSubProjects(usr, projects)
-	}
 
 	return usr, nil
 }
 
-func (c *Client) getGroup(ctx context.Context, group string, includeArchived bool) (root ProjectNode, err error) {
+func (c *Client) getGroup(ctx context.Context, group string, opts ProjectOptions) (root ProjectNode, err error) {
 	getProjects := func(archived bool) ([]*gl.Project, error) {
-		var projects []*gl.Project
-		tr := true
-		opts := &gl.ListGroupProjectsOptions{
-			IncludeSubgroups: &tr,
-			Archived:         &archived,
-			ListOptions: gl.ListOptions{
-				Page:    1,
-				PerPage: 100, // this is the max value from gitlab
-			},
-		}
-		for {
-			select {
-			case <-ctx.Done():
-				return nil, context.Canceled
-			default:
-			}
-
-			p, resp, err := c.c.Groups.ListGroupProjects(group, opts, gl.WithContext(ctx))
-			if err != nil {
-				return nil, err
+		if !opts.Filter.needsProjectsAPI() {
+			gopts := &groupProjectsOptions{ListGroupProjectsOptions: *opts.Filter.listGroupProjectsOptions(archived)}
+			if opts.Statistics {
+				gopts.Statistics = gl.Bool(true)
 			}
+			return listAll(ctx, &gopts.ListOptions, func() ([]*gl.Project, *gl.Response, error) {
+				return c.listGroupProjects(ctx, group, gopts)
+			})
+		}
 
-			projects = append(projects, p...)
+		// the group projects API cannot filter by membership and access level,
+		// so the projects of the user are listed and the ones in the group kept.
+		lopts := opts.Filter.listProjectsOptions(archived)
+		if opts.Statistics {
+			lopts.Statistics = gl.Bool(true)
+		}
+		projects, err := listAll(ctx, &lopts.ListOptions, func() ([]*gl.Project, *gl.Response, error) {
+			return c.c.Projects.ListProjects(lopts, gl.WithContext(ctx))
+		})
+		if err != nil {
+			return nil, err
+		}
 
-			// Exit the loop when we've seen all pages.
-			if resp.CurrentPage >= resp.TotalPages {
-				log.Debugf("got all results from the API")
-				break
+		var inGroup []*gl.Project
+		for _, p := range projects {
+			if strings.HasPrefix(normalize(p.PathWithNamespace), normalize(group)+"/") {
+				inGroup = append(inGroup, p)
 			}
-
-			// update page number to fetch next page
-			log.Debugf("getting next page from the API")
-			opts.Page = resp.NextPage
 		}
-
-		return projects, nil
+		return inGroup, nil
 	}
 
 	rootGroup, _, err := c.c.Groups.GetGroup(group)
@@ -204,24 +226,52 @@ func (c *Client) getGroup(ctx context.Context, group string, includeArchived boo
 
 	g := newGroup(rootGroup)
 
-	projects, err := getProjects(false)
+	// the groups are added first, so that empty subgroups are part of
+	// the tree and the projects are added to the real groups.
+	subGroups, err := c.listDescendantGroups(ctx, group)
 	if err != nil {
 		return nil, err
 	}
-	addSubProjects(g, projects)
+	addSubGroups(g, subGroups)
 
-	if includeArchived {
-		projects, err := getProjects(true)
-		if err != nil {
-			return nil, err
-		}
-
-		addSubProjects(g, projects)
+	if err := c.addProjects(ctx, g, opts, opts.Filter.needsProjectsAPI(), getProjects); err != nil {
+		return nil, err
 	}
 
 	return g, nil
 }
 
+// groupProjectsOptions extends the options of go-gitlab, which does not
+// support requesting statistics for the projects of a group.
+type groupProjectsOptions struct {
+	gl.ListGroupProjectsOptions
+	Statistics *bool `url:"statistics,omitempty" json:"statistics,omitempty"`
+}
+
+// listGroupProjects is like ListGroupProjects of go-gitlab, but with the
+// extended options.
+func (c *Client) listGroupProjects(ctx context.Context, group string, opts *groupProjectsOptions) ([]*gl.Project, *gl.Response, error) {
+	u := fmt.Sprintf("groups/%s/projects", pathEscape(group))
+
+	req, err := c.c.NewRequest(http.MethodGet, u, opts, []gl.RequestOptionFunc{gl.WithContext(ctx)})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var projects []*gl.Project
+	resp, err := c.c.Do(req, &projects)
+	if err != nil {
+		return nil, resp, err
+	}
+	return projects, resp, nil
+}
+
+// pathEscape escapes a path for the API like go-gitlab does, which
+// also escapes dots.
+func pathEscape(s string) string {
+	return strings.Replace(url.PathEscape(s), ".", "%2E", -1)
+}
+
 type Namespace string
 
 func (n Namespace) String() string                 { return string(n) }
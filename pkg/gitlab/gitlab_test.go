@@ -1,11 +1,116 @@
 package gitlab
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	gl "github.com/xanzy/go-gitlab"
 )
 
+// testTree returns the tree that is shared by the tests of printing,
+// encoding, sorting and statistics:
+//
+//	test/mygroup
+//	├─ api          (statistics unknown)
+//	├─ build
+//	│  ├─ buck      (archived)
+//	│  └─ shared
+//	│     └─ tools
+//	└─ myproject
+func testTree() ProjectNode {
+	day := func(d int) *time.Time {
+		t := time.Date(2020, 6, d, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	stats := func(storage, repo, lfs, artifacts int64, commits int) *gl.ProjectStatistics {
+		return &gl.ProjectStatistics{
+			StorageStatistics: gl.StorageStatistics{
+				StorageSize:      storage,
+				RepositorySize:   repo,
+				LfsObjectsSize:   lfs,
+				JobArtifactsSize: artifacts,
+			},
+			CommitCount: commits,
+		}
+	}
+
+	root := newGroup(&gl.Group{
+		ID:          1,
+		Name:        "mygroup",
+		FullPath:    "test/mygroup",
+		Description: "my group",
+		Visibility:  gl.PublicVisibility,
+		WebURL:      "https://gitlab.com/groups/test/mygroup",
+	})
+
+	addSubProjects(root, []*gl.Project{
+		{
+			ID:                2,
+			PathWithNamespace: "test/mygroup/myproject",
+			Name:              "myproject",
+			Description:       "myproject is a cool project",
+			WebURL:            "https://gitlab.com/test/mygroup/myproject",
+			SSHURLToRepo:      "git@gitlab.com:test/mygroup/myproject.git",
+			HTTPURLToRepo:     "https://gitlab.com/test/mygroup/myproject.git",
+			Namespace:         &gl.ProjectNamespace{FullPath: "test/mygroup"},
+			LastActivityAt:    day(3),
+			CreatedAt:         day(1),
+			StarCount:         1,
+			Statistics:        stats(100, 2048, 0, 1536, 10),
+		},
+		{
+			ID:                3,
+			PathWithNamespace: "test/mygroup/build/buck",
+			Name:              "buck",
+			Archived:          true,
+			Visibility:        gl.InternalVisibility,
+			Namespace:         &gl.ProjectNamespace{FullPath: "test/mygroup/build"},
+			LastActivityAt:    day(2),
+			CreatedAt:         day(2),
+			StarCount:         3,
+			Statistics:        stats(60, 512, 0, 0, 3),
+		},
+		{
+			ID:                4,
+			PathWithNamespace: "test/mygroup/build/shared/tools",
+			Name:              "tools",
+			Namespace:         &gl.ProjectNamespace{FullPath: "test/mygroup/build/shared"},
+			LastActivityAt:    day(4),
+			CreatedAt:         day(3),
+			Statistics:        stats(50, 1024, 3*1024*1024, 0, 5),
+		},
+		{
+			ID:                5,
+			PathWithNamespace: "test/mygroup/api",
+			Name:              "api",
+			Namespace:         &gl.ProjectNamespace{FullPath: "test/mygroup"},
+			LastActivityAt:    day(1),
+			CreatedAt:         day(5),
+			StarCount:         5,
+		},
+	})
+	return root
+}
+
+// newTestClient creates a client for a test server that serves the mux.
+// The server is closed when the test has finished.
+func newTestClient(t *testing.T, mux *http.ServeMux) *gl.Client {
+	t.Helper()
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	cl, err := gl.NewClient("token", gl.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	return cl
+}
+
 func TestConstructProjectTreeCase(t *testing.T) {
 	rootGroup := &gl.Group{
 		Name:     "GROUP",
@@ -187,3 +292,36 @@ func TestNamespace(t *testing.T) {
 		t.Errorf("namespace joined not correct. expected=%q, got=%q", "test/my/group/sub/group", n.Join("sub,", "group"))
 	}
 }
+
+func TestGetProjectsStatistics(t *testing.T) {
+	var query string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/namespaces/my.group", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 1, "full_path": "my.group", "kind": "group"}`)
+	})
+	mux.HandleFunc("/api/v4/groups/my.group", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 1, "name": "my.group", "full_path": "my.group"}`)
+	})
+	mux.HandleFunc("/api/v4/groups/my.group/descendant_groups", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+	mux.HandleFunc("/api/v4/groups/my.group/projects", func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("statistics")
+		fmt.Fprint(w, `[{"id": 2, "name": "api", "path_with_namespace": "my.group/api", "namespace": {"full_path": "my.group"},
+			"statistics": {"storage_size": 1024}}]`)
+	})
+
+	root, err := New(newTestClient(t, mux), "my.group").GetProjects(context.Background(), ProjectOptions{Statistics: true})
+	if err != nil {
+		t.Fatalf("could not get projects: %v", err)
+	}
+
+	if query != "true" {
+		t.Errorf("statistics have not been requested. got=%q", query)
+	}
+
+	p, ok := root.(noder).getNode("api").(*Project)
+	if !ok || p.gp.Statistics == nil || p.gp.Statistics.StorageSize != 1024 {
+		t.Errorf("project statistics not correct. got=%+v", p)
+	}
+}
@@ -0,0 +1,131 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tommyknows/gitlab-cli/pkg/log"
+	gl "github.com/xanzy/go-gitlab"
+)
+
+// listDescendantGroups returns all groups below the group, at any depth.
+// Instances before Gitlab 13.5 do not know descendant groups, for these
+// the subgroups are listed group by group.
+func (c *Client) listDescendantGroups(ctx context.Context, group string) ([]*gl.Group, error) {
+	var (
+		groups []*gl.Group
+		opts   = &gl.ListGroupsOptions{
+			ListOptions: gl.ListOptions{
+				Page:    1,
+				PerPage: 100, // this is the max value from gitlab
+			},
+		}
+		u        = fmt.Sprintf("groups/%s/descendant_groups", pathEscape(group))
+		notFound bool
+	)
+
+	err := paginate(ctx, &opts.ListOptions, func() (*gl.Response, error) {
+		req, err := c.c.NewRequest(http.MethodGet, u, opts, []gl.RequestOptionFunc{gl.WithContext(ctx)})
+		if err != nil {
+			return nil, err
+		}
+
+		var g []*gl.Group
+		resp, err := c.c.Do(req, &g)
+		notFound = resp != nil && resp.StatusCode == http.StatusNotFound
+		groups = append(groups, g...)
+		return resp, err
+	})
+
+	switch {
+	case notFound:
+		log.Debugf("instance does not know descendant groups, listing the subgroups of %v", group)
+		return c.listSubgroups(ctx, group)
+	case err != nil:
+		return nil, errors.Wrapf(err, "could not list the groups below %v", group)
+	}
+	return groups, nil
+}
+
+// listSubgroups returns all groups below the group, by listing the
+// subgroups of every group.
+func (c *Client) listSubgroups(ctx context.Context, group string) ([]*gl.Group, error) {
+	var (
+		groups  []*gl.Group
+		parents = []string{group}
+	)
+
+	for len(parents) > 0 {
+		parent := parents[0]
+		parents = parents[1:]
+
+		opts := &gl.ListSubgroupsOptions{
+			ListOptions: gl.ListOptions{
+				Page:    1,
+				PerPage: 100, // this is the max value from gitlab
+			},
+		}
+
+		err := paginate(ctx, &opts.ListOptions, func() (*gl.Response, error) {
+			g, resp, err := c.c.Groups.ListSubgroups(parent, opts, gl.WithContext(ctx))
+			for _, sub := range g {
+				groups = append(groups, sub)
+				parents = append(parents, sub.FullPath)
+			}
+			return resp, err
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not list the subgroups of %v", parent)
+		}
+	}
+	return groups, nil
+}
+
+// addSubGroups adds the groups to the tree below rootNode. Groups
+// whose parent is not in the tree are skipped.
+func addSubGroups(rootNode noder, subGroups []*gl.Group) {
+	// parents must be added before their subgroups.
+	sorted := make([]*gl.Group, len(subGroups))
+	copy(sorted, subGroups)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return strings.Count(sorted[i].FullPath, "/") < strings.Count(sorted[j].FullPath, "/")
+	})
+
+	for _, subGroup := range sorted {
+		namespaces := Namespace(subGroup.FullPath).relative(rootNode.FullPath()).elements()
+		if len(namespaces) == 0 {
+			continue
+		}
+
+		parent := rootNode
+		for _, ns := range namespaces[:len(namespaces)-1] {
+			n, ok := parent.getNode(ns).(noder)
+			if !ok {
+				parent = nil
+				break
+			}
+			parent = n
+		}
+
+		if parent == nil {
+			log.Debugf("skipping group %v, its parent group is not known", subGroup.FullPath)
+			continue
+		}
+
+		if parent.getNode(namespaces[len(namespaces)-1]) != nil {
+			continue
+		}
+
+		g := newGroup(subGroup)
+		// the namespace must match the full path of the parent, which
+		// may differ in case from the normalized path of the group.
+		g.namespace = parent.FullPath()
+		parent.addNodes(g)
+	}
+
+	sortTree(rootNode)
+}
@@ -0,0 +1,112 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	gl "github.com/xanzy/go-gitlab"
+)
+
+func TestAddSubGroups(t *testing.T) {
+	root := newGroup(&gl.Group{Name: "My Group", FullPath: "test/my-group"})
+
+	// subgroups are listed in no particular order.
+	addSubGroups(root, []*gl.Group{
+		{ID: 3, Name: "Shared Tools", FullPath: "test/my-group/build/shared-tools", Description: "tools", Visibility: gl.InternalVisibility},
+		{ID: 2, Name: "Build", FullPath: "test/my-group/build", Description: "build systems"},
+		{ID: 4, Name: "Empty", FullPath: "test/my-group/Empty"},
+		{ID: 5, Name: "Orphan", FullPath: "test/my-group/unknown/orphan"},
+	})
+
+	addSubProjects(root, []*gl.Project{
+		{Name: "Bazel", PathWithNamespace: "test/my-group/build/bazel", Namespace: &gl.ProjectNamespace{FullPath: "test/my-group/build"}},
+		{Name: "lint", PathWithNamespace: "test/my-group/build/shared-tools/lint", Namespace: &gl.ProjectNamespace{FullPath: "test/my-group/build/shared-tools"}},
+	})
+
+	var nodes []string
+	_ = Walk(root, func(p ProjectNode) error {
+		nodes = append(nodes, fmt.Sprintf("%v:%v", p.Name(), p.FullPath()))
+		return nil
+	})
+
+	expected := []string{
+		"My Group:test/my-group",
+		"Build:test/my-group/build",
+		"Bazel:test/my-group/build/bazel",
+		"Shared Tools:test/my-group/build/shared-tools",
+		"lint:test/my-group/build/shared-tools/lint",
+		"Empty:test/my-group/Empty",
+	}
+	if !reflect.DeepEqual(nodes, expected) {
+		t.Errorf("tree not correct. expected=%v, got=%v", expected, nodes)
+	}
+
+	build := root.getNode("build").(*Group)
+	if build.id != 2 || build.description != "build systems" || build.Namespace() != "test/my-group" {
+		t.Errorf("group not correct. got=%+v", build)
+	}
+
+	tools := build.getNode("SHARED-TOOLS").(*Group)
+	if tools.visibility != gl.InternalVisibility || tools.Depth() != 3 {
+		t.Errorf("group not correct. got=%+v", tools)
+	}
+
+	actual := PrintProject(root, PrintOptions{PrintDescription: true})
+
+	// the whitespace is important because of padding
+	expectedOutput := `name                 type      group                              description
+----                 ----      -----                              -----------
+My Group             group     test                               
+├─ Build             group     test/my-group                      build systems
+│  ├─ Bazel          project   test/my-group/build                
+│  └─ Shared Tools   group     test/my-group/build                tools
+│     └─ lint        project   test/my-group/build/shared-tools   
+└─ Empty             group     test/my-group                      
+`
+	if actual != expectedOutput {
+		t.Errorf("PrintProject output differs. expected=\n%sgot=\n%s", expectedOutput, actual)
+	}
+}
+
+func TestListDescendantGroups(t *testing.T) {
+	var descendants bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/groups/root/descendant_groups", func(w http.ResponseWriter, r *http.Request) {
+		if !descendants {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[{"id": 2, "full_path": "root/a"}, {"id": 3, "full_path": "root/a/b"}]`)
+	})
+	mux.HandleFunc("/api/v4/groups/root/subgroups", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": 2, "full_path": "root/a"}]`)
+	})
+	mux.HandleFunc("/api/v4/groups/root/a/subgroups", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": 3, "full_path": "root/a/b"}]`)
+	})
+	mux.HandleFunc("/api/v4/groups/root/a/b/subgroups", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+
+	cl := newTestClient(t, mux)
+
+	// instances before 13.5 only know subgroups.
+	for _, descendants = range []bool{true, false} {
+		groups, err := New(cl, "root").listDescendantGroups(context.Background(), "root")
+		if err != nil {
+			t.Fatalf("could not list groups (descendant groups=%v): %v", descendants, err)
+		}
+
+		var paths []string
+		for _, g := range groups {
+			paths = append(paths, g.FullPath)
+		}
+
+		if expected := []string{"root/a", "root/a/b"}; !reflect.DeepEqual(paths, expected) {
+			t.Errorf("groups not correct (descendant groups=%v). expected=%v, got=%v", descendants, expected, paths)
+		}
+	}
+}
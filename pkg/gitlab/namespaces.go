@@ -0,0 +1,225 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/tommyknows/gitlab-cli/pkg/log"
+	gl "github.com/xanzy/go-gitlab"
+)
+
+// NamespaceKind describes what a namespace refers to.
+type NamespaceKind string
+
+const (
+	GroupKind   NamespaceKind = "group"
+	UserKind    NamespaceKind = "user"
+	ProjectKind NamespaceKind = "project"
+)
+
+// LookupNamespace returns the kind of the client's namespace. If it
+// does not exist, ErrNotFound is returned.
+func (c *Client) LookupNamespace(ctx context.Context) (NamespaceKind, error) {
+	kind, _, err := c.lookupNamespace(ctx)
+	return kind, err
+}
+
+// lookupNamespace looks up the kind of the client's namespace. If it is
+// a project, the project is returned too.
+func (c *Client) lookupNamespace(ctx context.Context) (NamespaceKind, *gl.Project, error) {
+	ns, resp, err := c.c.Namespaces.GetNamespace(url.QueryEscape(c.namespace), gl.WithContext(ctx))
+	if err == nil {
+		switch kind := NamespaceKind(ns.Kind); kind {
+		case GroupKind, UserKind:
+			return kind, nil, nil
+		default:
+			return "", nil, errors.New("unknown kind: " + ns.Kind)
+		}
+	}
+
+	// some connection error - no internet connection for example.
+	if resp == nil && err != nil {
+		return "", nil, err
+	}
+
+	// it could be that the namespace really not exists.
+	// it could also be that it is a project. In this case,
+	// (and we don't really know), we try getting the project.
+	if resp != nil && resp.StatusCode != http.StatusNotFound {
+		return "", nil, err
+	}
+
+	tr := true
+	p, resp, err := c.c.Projects.GetProject(c.namespace, &gl.GetProjectOptions{
+		Statistics: &tr,
+	}, gl.WithContext(ctx))
+	if err == nil {
+		return ProjectKind, p, nil
+	}
+
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return "", nil, err
+	}
+	// the error message is a lengthy string that includes the
+	// URL of the request. We don't need it, as we know exactly
+	// what it is, so we just create a custom error
+	return "", nil, ErrNotFound
+}
+
+// ProjectRef references a single project on an instance.
+type ProjectRef struct {
+	ID            int
+	FullPath      string
+	DefaultBranch string
+}
+
+// GetProjectRef returns a reference to the project at the client's
+// namespace. If it does not exist, ErrNotFound is returned.
+func (c *Client) GetProjectRef(ctx context.Context) (*ProjectRef, error) {
+	p, resp, err := c.c.Projects.GetProject(c.namespace, nil, gl.WithContext(ctx))
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &ProjectRef{
+		ID:            p.ID,
+		FullPath:      p.PathWithNamespace,
+		DefaultBranch: p.DefaultBranch,
+	}, nil
+}
+
+// maxSuggestionPages limits the number of group pages that are
+// fetched for suggestions, as there may be a lot of them.
+const maxSuggestionPages = 10
+
+// SuggestNamespaces returns up to max namespaces that are similar to the
+// client's namespace, the most similar first. The candidates are the
+// namespaces returned by ListNamespaces.
+func (c *Client) SuggestNamespaces(ctx context.Context, max int) ([]string, error) {
+	candidates, err := c.ListNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return suggest(c.namespace, candidates, max), nil
+}
+
+// ListNamespaces returns the full paths of the groups of the authenticated
+// user, and the user itself. At most maxSuggestionPages pages of groups
+// are fetched.
+func (c *Client) ListNamespaces(ctx context.Context) ([]string, error) {
+	var namespaces []string
+
+	if u, _, err := c.c.Users.CurrentUser(gl.WithContext(ctx)); err == nil {
+		namespaces = append(namespaces, u.Username)
+	}
+
+	opts := &gl.ListGroupsOptions{
+		ListOptions: gl.ListOptions{
+			Page:    1,
+			PerPage: 100, // this is the max value from gitlab
+		},
+	}
+	for {
+		groups, resp, err := c.c.Groups.ListGroups(opts, gl.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, g := range groups {
+			namespaces = append(namespaces, g.FullPath)
+		}
+
+		if resp.CurrentPage >= resp.TotalPages || resp.CurrentPage >= maxSuggestionPages {
+			break
+		}
+
+		log.Debugf("getting next page from the API")
+		opts.Page = resp.NextPage
+	}
+
+	return namespaces, nil
+}
+
+// suggest returns up to max candidates that are similar to the query,
+// sorted by their similarity.
+func suggest(query string, candidates []string, max int) []string {
+	type match struct {
+		candidate string
+		distance  int
+	}
+
+	query = normalize(query)
+	lastElement := query[strings.LastIndex(query, "/")+1:]
+	threshold := len(query)/3 + 1
+
+	var matches []match
+	for _, c := range candidates {
+		nc := normalize(c)
+		d := levenshtein(query, nc)
+
+		// the namespace may have been moved to another group.
+		if d > 1 && strings.HasSuffix(nc, "/"+lastElement) {
+			d = 1
+		}
+
+		if d <= threshold && d > 0 {
+			matches = append(matches, match{c, d})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].candidate < matches[j].candidate
+	})
+
+	var suggestions []string
+	for i := 0; i < len(matches) && i < max; i++ {
+		suggestions = append(suggestions, matches[i].candidate)
+	}
+	return suggestions
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,122 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestLookupNamespace(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/namespaces/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/v4/namespaces/platform":
+			fmt.Fprint(w, `{"id": 1, "full_path": "platform", "kind": "group"}`)
+		case "/api/v4/namespaces/tommyknows":
+			fmt.Fprint(w, `{"id": 2, "full_path": "tommyknows", "kind": "user"}`)
+		default:
+			http.Error(w, `{"message": "404 Namespace Not Found"}`, http.StatusNotFound)
+		}
+	})
+	mux.HandleFunc("/api/v4/projects/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() == "/api/v4/projects/platform%2Fcli" {
+			fmt.Fprint(w, `{"id": 3, "path_with_namespace": "platform/cli"}`)
+			return
+		}
+		http.Error(w, `{"message": "404 Project Not Found"}`, http.StatusNotFound)
+	})
+
+	cl := newTestClient(t, mux)
+
+	tests := []struct {
+		namespace string
+		kind      NamespaceKind
+		err       error
+	}{
+		{"platform", GroupKind, nil},
+		{"tommyknows", UserKind, nil},
+		{"platform/cli", ProjectKind, nil},
+		{"platfrom", "", ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		kind, err := New(cl, tt.namespace).LookupNamespace(context.Background())
+		if err != tt.err {
+			t.Errorf("%v: error not correct. expected=%v, got=%v", tt.namespace, tt.err, err)
+		}
+
+		if kind != tt.kind {
+			t.Errorf("%v: kind not correct. expected=%q, got=%q", tt.namespace, tt.kind, kind)
+		}
+	}
+}
+
+func TestGetProjectRef(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/projects/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() == "/api/v4/projects/platform%2Fcli" {
+			fmt.Fprint(w, `{"id": 3, "path_with_namespace": "platform/cli", "default_branch": "main"}`)
+			return
+		}
+		http.Error(w, `{"message": "404 Project Not Found"}`, http.StatusNotFound)
+	})
+
+	cl := newTestClient(t, mux)
+
+	ref, err := New(cl, "platform/cli").GetProjectRef(context.Background())
+	if err != nil {
+		t.Fatalf("could not get project: %v", err)
+	}
+
+	expected := &ProjectRef{ID: 3, FullPath: "platform/cli", DefaultBranch: "main"}
+	if !reflect.DeepEqual(ref, expected) {
+		t.Errorf("project not correct. expected=%+v, got=%+v", expected, ref)
+	}
+
+	if _, err := New(cl, "platform/nonexistent").GetProjectRef(context.Background()); err != ErrNotFound {
+		t.Errorf("error not correct. expected=%v, got=%v", ErrNotFound, err)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"platform", "platform/infra", "tommyknows", "team/cli", "marketing"}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"platfrom", []string{"platform"}},
+		{"platform/infar", []string{"platform/infra"}},
+		{"tools/cli", []string{"team/cli"}},
+		{"Tommyknows", nil},
+		{"something-else", nil},
+	}
+
+	for _, tt := range tests {
+		suggestions := suggest(tt.query, candidates, 5)
+		if !reflect.DeepEqual(suggestions, tt.expected) {
+			t.Errorf("%v: suggestions not correct. expected=%v, got=%v", tt.query, tt.expected, suggestions)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"group", "", 5},
+		{"group", "group", 0},
+		{"group", "gruop", 2},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, tt := range tests {
+		if d := levenshtein(tt.a, tt.b); d != tt.distance {
+			t.Errorf("distance between %q and %q not correct. expected=%v, got=%v", tt.a, tt.b, tt.distance, d)
+		}
+	}
+}
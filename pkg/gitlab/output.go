@@ -0,0 +1,214 @@
+package gitlab
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// Output formats of EncodeProject, besides TemplateOutputPrefix.
+const (
+	JSONOutput = "json"
+	YAMLOutput = "yaml"
+	CSVOutput  = "csv"
+	// TemplateOutputPrefix is followed by a Go template, which is
+	// executed with the root Node, or all Nodes if they are flattened.
+	TemplateOutputPrefix = "go-template="
+)
+
+// Types of a Node.
+const (
+	ProjectType = "project"
+	GroupType   = "group"
+	UserType    = "user"
+)
+
+// Node is the machine-readable form of a ProjectNode. Fields that
+// are not known for a node are left empty, e.g. the ID of groups
+// that the user can only see through their projects.
+type Node struct {
+	ID          int    `json:"id,omitempty"`
+	Name        string `json:"name"`
+	FullPath    string `json:"fullPath"`
+	Type        string `json:"type"`
+	Archived    bool   `json:"archived"`
+	Description string `json:"description,omitempty"`
+	Visibility  string `json:"visibility,omitempty"`
+	WebURL      string `json:"webURL,omitempty"`
+	SSHURL      string `json:"sshURL,omitempty"`
+	HTTPURL     string `json:"httpURL,omitempty"`
+	// Statistics are only set if they have been requested and are known,
+	// see NodeStatistics.
+	Statistics *Statistics `json:"statistics,omitempty"`
+
+	Nodes []*Node `json:"nodes,omitempty"`
+}
+
+// EncodeOptions configure EncodeProject.
+type EncodeOptions struct {
+	PrintOptions
+	// Format is one of the output formats, e.g. JSONOutput.
+	Format string
+	// Flat encodes a list of all nodes instead of the tree. CSV is
+	// always flat.
+	Flat bool
+}
+
+// NewNode converts the tree of the ProjectNode, skipping archived
+// projects and nodes below the depth according to the options. The
+// root is always converted, even if it is an archived project.
+func NewNode(root ProjectNode, opts PrintOptions) *Node {
+	var convert func(p ProjectNode) *Node
+	convert = func(p ProjectNode) *Node {
+		if opts.Depth != 0 && p.Depth()-root.Depth() > opts.Depth {
+			return nil
+		}
+
+		n := &Node{
+			Name:     p.Name(),
+			FullPath: p.FullPath().String(),
+		}
+
+		switch p := p.(type) {
+		case *Project:
+			if p.gp.Archived && !opts.PrintArchived && p != root {
+				return nil
+			}
+			n.Type = ProjectType
+			n.ID = p.gp.ID
+			n.Archived = p.gp.Archived
+			n.Description = p.gp.Description
+			n.Visibility = string(p.gp.Visibility)
+			n.WebURL = p.gp.WebURL
+			n.SSHURL = p.gp.SSHURLToRepo
+			n.HTTPURL = p.gp.HTTPURLToRepo
+
+		case *Group:
+			n.Type = GroupType
+			n.ID = p.id
+			n.Description = p.description
+			n.Visibility = string(p.visibility)
+			n.WebURL = p.webURL
+
+		case *User:
+			n.Type = UserType
+			n.ID = p.id
+		}
+
+		if opts.PrintStatistics {
+			if s, ok := NodeStatistics(p); ok {
+				n.Statistics = &s
+			}
+		}
+
+		if nd, ok := p.(noder); ok {
+			for _, sub := range nd.nodes() {
+				if s := convert(sub); s != nil {
+					n.Nodes = append(n.Nodes, s)
+				}
+			}
+		}
+		return n
+	}
+
+	return convert(root)
+}
+
+// Flatten returns the node and all nodes below it depth-first, without
+// their sub-nodes.
+func (n *Node) Flatten() []*Node {
+	flat := *n
+	flat.Nodes = nil
+
+	nodes := []*Node{&flat}
+	for _, sub := range n.Nodes {
+		nodes = append(nodes, sub.Flatten()...)
+	}
+	return nodes
+}
+
+// EncodeProject writes the tree of the ProjectNode to w in the format
+// of the options.
+func EncodeProject(w io.Writer, root ProjectNode, opts EncodeOptions) error {
+	node := NewNode(root, opts.PrintOptions)
+
+	var v interface{} = node
+	if opts.Flat {
+		v = node.Flatten()
+	}
+
+	switch {
+	case opts.Format == JSONOutput:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+
+	case opts.Format == YAMLOutput:
+		out, err := yaml.Marshal(v)
+		if err != nil {
+			return errors.Wrapf(err, "could not marshal projects")
+		}
+		_, err = w.Write(out)
+		return err
+
+	case opts.Format == CSVOutput:
+		return encodeCSV(w, node.Flatten(), opts.PrintStatistics)
+
+	case strings.HasPrefix(opts.Format, TemplateOutputPrefix):
+		tmpl, err := template.New("output").Parse(strings.TrimPrefix(opts.Format, TemplateOutputPrefix))
+		if err != nil {
+			return errors.Wrapf(err, "could not parse template")
+		}
+		return tmpl.Execute(w, v)
+	}
+
+	return errors.Errorf("unknown output format %q, must be one of %v, %v, %v or %v<template>",
+		opts.Format, JSONOutput, YAMLOutput, CSVOutput, TemplateOutputPrefix)
+}
+
+func encodeCSV(w io.Writer, nodes []*Node, statistics bool) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{"id", "type", "full_path", "name", "archived", "description", "visibility", "web_url", "ssh_url", "http_url"}
+	if statistics {
+		header = append(header, "repository_size", "lfs_objects_size", "job_artifacts_size", "commit_count", "statistics_partial")
+	}
+
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, n := range nodes {
+		var id string
+		if n.ID != 0 {
+			id = strconv.Itoa(n.ID)
+		}
+
+		record := []string{id, n.Type, n.FullPath, n.Name, strconv.FormatBool(n.Archived), n.Description, n.Visibility, n.WebURL, n.SSHURL, n.HTTPURL}
+		switch s := n.Statistics; {
+		case statistics && s != nil:
+			record = append(record,
+				strconv.FormatInt(s.RepositorySize, 10),
+				strconv.FormatInt(s.LFSObjectsSize, 10),
+				strconv.FormatInt(s.JobArtifactsSize, 10),
+				strconv.Itoa(s.CommitCount),
+				strconv.FormatBool(s.Partial))
+		case statistics:
+			// unknown statistics are left empty, unlike zeros.
+			record = append(record, "", "", "", "", "")
+		}
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
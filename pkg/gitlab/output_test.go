@@ -0,0 +1,137 @@
+package gitlab
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+)
+
+func TestNewNode(t *testing.T) {
+	node := NewNode(testTree(), PrintOptions{PrintArchived: true})
+
+	var paths []string
+	for _, n := range node.Flatten() {
+		if n.Nodes != nil {
+			t.Errorf("flattened node %v has sub-nodes", n.FullPath)
+		}
+		paths = append(paths, n.Type+":"+n.FullPath)
+	}
+
+	expected := []string{
+		"group:test/mygroup",
+		"project:test/mygroup/api",
+		"group:test/mygroup/build",
+		"project:test/mygroup/build/buck",
+		"group:test/mygroup/build/shared",
+		"project:test/mygroup/build/shared/tools",
+		"project:test/mygroup/myproject",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("nodes not correct. expected=%v, got=%v", expected, paths)
+	}
+
+	// without archived projects and limited depth
+	node = NewNode(testTree(), PrintOptions{Depth: 2})
+	if n := len(node.Flatten()); n != 5 {
+		t.Errorf("number of nodes not correct. expected=5, got=%v", n)
+	}
+
+	if build := node.Nodes[1]; len(build.Nodes) != 1 || build.Nodes[0].Name != "shared" {
+		t.Errorf("archived project has not been skipped. got=%+v", build.Nodes)
+	}
+}
+
+func TestEncodeProject(t *testing.T) {
+	opts := func(format string, flat bool) EncodeOptions {
+		return EncodeOptions{PrintOptions: PrintOptions{PrintArchived: true}, Format: format, Flat: flat}
+	}
+
+	var b bytes.Buffer
+	if err := EncodeProject(&b, testTree(), opts(JSONOutput, false)); err != nil {
+		t.Fatalf("could not encode json: %v", err)
+	}
+
+	var root Node
+	if err := json.Unmarshal(b.Bytes(), &root); err != nil {
+		t.Fatalf("could not decode json: %v", err)
+	}
+
+	if root.ID != 1 || root.Description != "my group" || len(root.Nodes) != 3 {
+		t.Errorf("root node not correct. got=%+v", root)
+	}
+
+	project := root.Nodes[2]
+	if project.ID != 2 || project.SSHURL != "git@gitlab.com:test/mygroup/myproject.git" || project.Type != ProjectType {
+		t.Errorf("project node not correct. got=%+v", project)
+	}
+
+	b.Reset()
+	if err := EncodeProject(&b, testTree(), opts(YAMLOutput, true)); err != nil {
+		t.Fatalf("could not encode yaml: %v", err)
+	}
+
+	var nodes []Node
+	if err := yaml.Unmarshal(b.Bytes(), &nodes); err != nil {
+		t.Fatalf("could not decode yaml: %v", err)
+	}
+
+	if len(nodes) != 7 || !nodes[3].Archived {
+		t.Errorf("flat nodes not correct. got=%+v", nodes)
+	}
+
+	b.Reset()
+	if err := EncodeProject(&b, testTree(), opts(CSVOutput, false)); err != nil {
+		t.Fatalf("could not encode csv: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 8 {
+		t.Fatalf("number of csv lines not correct. expected=8, got=%v", len(lines))
+	}
+
+	if expected := "id,type,full_path,name,archived,description,visibility,web_url,ssh_url,http_url"; lines[0] != expected {
+		t.Errorf("csv header not correct. expected=%q, got=%q", expected, lines[0])
+	}
+
+	if expected := "3,project,test/mygroup/build/buck,buck,true,,internal,,,"; lines[4] != expected {
+		t.Errorf("csv line not correct. expected=%q, got=%q", expected, lines[4])
+	}
+
+	b.Reset()
+	if err := EncodeProject(&b, testTree(), opts(TemplateOutputPrefix+`{{range .}}{{if eq .Type "project"}}{{.FullPath}} {{end}}{{end}}`, true)); err != nil {
+		t.Fatalf("could not execute template: %v", err)
+	}
+
+	if expected := "test/mygroup/api test/mygroup/build/buck test/mygroup/build/shared/tools test/mygroup/myproject "; b.String() != expected {
+		t.Errorf("template output not correct. expected=%q, got=%q", expected, b.String())
+	}
+
+	if err := EncodeProject(&b, testTree(), opts("xml", false)); err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+}
+
+func TestEncodeArchivedRoot(t *testing.T) {
+	root := testTree().(noder).getNode("build").(noder).getNode("buck")
+
+	for _, flat := range []bool{false, true} {
+		for _, format := range []string{JSONOutput, YAMLOutput, CSVOutput} {
+			var b bytes.Buffer
+			if err := EncodeProject(&b, root, EncodeOptions{Format: format, Flat: flat}); err != nil {
+				t.Fatalf("%v: could not encode: %v", format, err)
+			}
+
+			if !strings.Contains(b.String(), "test/mygroup/build/buck") {
+				t.Errorf("%v (flat=%v): archived root is missing. got=%q", format, flat, b.String())
+			}
+		}
+	}
+
+	if out := PrintProject(root, PrintOptions{}); !strings.Contains(out, "buck") {
+		t.Errorf("archived root is not printed. got=%q", out)
+	}
+}
@@ -3,6 +3,7 @@ package gitlab
 import (
 	"context"
 	"fmt"
+	"path"
 	"sort"
 	"strings"
 	"text/tabwriter"
@@ -39,7 +40,9 @@ type noder interface {
 	ProjectNode
 	nodes() []ProjectNode
 	addNodes(...ProjectNode)
-	getNode(name string) ProjectNode
+	setNodes([]ProjectNode)
+	// getNode returns the sub-node whose path is pathName, ignoring case.
+	getNode(pathName string) ProjectNode
 	numNodes(includeArchived bool) int
 }
 
@@ -103,7 +106,10 @@ func sortNodes(s []ProjectNode) {
 type PrintOptions struct {
 	PrintArchived,
 	PrintDescription bool
-	Depth int
+	// PrintStatistics prints the sizes and the commit count of the
+	// projects, and their totals for groups and users.
+	PrintStatistics bool
+	Depth           int
 }
 
 // PrintProject pretty-prints a projectNode with the supplied settings.
@@ -115,8 +121,7 @@ func PrintProject(g ProjectNode, opts PrintOptions) string {
 	)
 
 	var (
-		header   = "name\ttype\tgroup\n----\t----\t-----\n"
-		printFmt = "%s\t%s\t%s\n"
+		columns = []string{"name", "type", "group"}
 
 		b         = new(strings.Builder)
 		tabWrite  = tabwriter.NewWriter(b, 4, 5, 3, ' ', tabwriter.StripEscape)
@@ -124,30 +129,37 @@ func PrintProject(g ProjectNode, opts PrintOptions) string {
 	)
 
 	if opts.PrintDescription {
-		header = "name\ttype\tgroup\tdescription\n----\t----\t-----\t-----------\n"
-		printFmt = "%s\t%s\t%s\t%s\n"
+		columns = append(columns, "description")
 	}
 
-	fmt.Fprint(tabWrite, header)
+	if opts.PrintStatistics {
+		columns = append(columns, "repository", "lfs", "artifacts", "commits")
+	}
+
+	printRow := func(values ...string) {
+		fmt.Fprint(tabWrite, strings.Join(values, "\t")+"\n")
+	}
+
+	printRow(columns...)
+	underlines := make([]string, len(columns))
+	for i, c := range columns {
+		underlines[i] = strings.Repeat("-", len(c))
+	}
+	printRow(underlines...)
 
 	writers := map[Namespace]treewriter.Writer{
 		g.Namespace(): treeWrite,
 	}
 
-	printProject := func(name, typ string, ns Namespace, description string) {
+	printNode := func(name, typ string, p ProjectNode, description string) {
+		values := []string{name, typ, p.Namespace().String()}
 		if opts.PrintDescription {
-			fmt.Fprintf(tabWrite, printFmt, name, typ, ns, description)
-		} else {
-			fmt.Fprintf(tabWrite, printFmt, name, typ, ns)
+			values = append(values, description)
 		}
-	}
-
-	printGroup := func(name, typ string, ns Namespace) {
-		if opts.PrintDescription {
-			fmt.Fprintf(tabWrite, printFmt, name, typ, ns, "")
-		} else {
-			fmt.Fprintf(tabWrite, printFmt, name, typ, ns)
+		if opts.PrintStatistics {
+			values = append(values, statisticsColumns(p)...)
 		}
+		printRow(values...)
 	}
 
 	depthReached := func(d int) bool {
@@ -176,13 +188,15 @@ func PrintProject(g ProjectNode, opts PrintOptions) string {
 			typ := project
 
 			if n.gp.Archived {
-				if !opts.PrintArchived {
+				// the root has been asked for explicitly, so it is
+				// printed even if it is archived.
+				if !opts.PrintArchived && p != g {
 					return nil
 				}
 				typ += archived
 			}
 
-			printProject(tw.Element(n.Name()), typ, n.Namespace(), n.gp.Description)
+			printNode(tw.Element(n.Name()), typ, n, n.gp.Description)
 
 		case noder:
 			tw, ok := writers[n.Namespace()]
@@ -190,7 +204,12 @@ func PrintProject(g ProjectNode, opts PrintOptions) string {
 				return errors.Errorf("no writer for group %v", n.FullPath())
 			}
 
-			printGroup(tw.Element(n.Name()), group, n.Namespace())
+			var description string
+			if g, ok := n.(*Group); ok {
+				description = g.description
+			}
+
+			printNode(tw.Element(n.Name()), group, n, description)
 
 			writers[n.FullPath()] = tw.Sub(n.numNodes(opts.PrintArchived))
 		}
@@ -209,6 +228,8 @@ func PrintProject(g ProjectNode, opts PrintOptions) string {
 	return b.String()
 }
 
+// addSubProjects adds the projects to the tree below rootNode, creating
+// the groups on their path.
 func addSubProjects(rootNode noder, subProjects []*gl.Project) {
 	for _, subProj := range subProjects {
 		namespaces := Namespace(subProj.PathWithNamespace).relative(rootNode.FullPath()).elements()
@@ -229,7 +250,8 @@ func addSubProjects(rootNode noder, subProjects []*gl.Project) {
 				panic("node found but is project, not group")
 
 			default:
-				// no match found, create node
+				// no match found, the group has not been fetched from the API,
+				// e.g. because the user can see the project but not the group.
 				subGroup := &Group{
 					name:      ns,
 					namespace: rootNode.FullPath().Join(namespaces[:i]...),
@@ -246,6 +268,11 @@ func addSubProjects(rootNode noder, subProjects []*gl.Project) {
 		}
 	}
 
+	sortTree(rootNode)
+}
+
+// sortTree sorts every level of the tree below rootNode by name.
+func sortTree(rootNode noder) {
 	// we never return an error, so we don't expect one from walk.
 	_ = Walk(rootNode, func(p ProjectNode) error {
 		n, ok := p.(noder)
@@ -258,7 +285,31 @@ func addSubProjects(rootNode noder, subProjects []*gl.Project) {
 	})
 }
 
+// pruneEmptyGroups removes the groups below root that contain no
+// projects, e.g. because all of them have been filtered.
+func pruneEmptyGroups(root noder) {
+	var hasProjects func(p ProjectNode) bool
+	hasProjects = func(p ProjectNode) bool {
+		n, ok := p.(noder)
+		if !ok {
+			return true
+		}
+
+		var kept []ProjectNode
+		for _, sub := range n.nodes() {
+			if hasProjects(sub) {
+				kept = append(kept, sub)
+			}
+		}
+		n.setNodes(kept)
+		return len(kept) > 0
+	}
+
+	hasProjects(root)
+}
+
 type User struct {
+	id       int
 	fullname string
 	username string
 
@@ -272,13 +323,9 @@ func (u *User) FullPath() Namespace       { return Namespace(u.username) }
 func (u *User) Depth() int                { return 1 }
 func (u *User) addNodes(n ...ProjectNode) { u.projects = append(u.projects, n...) }
 func (u *User) nodes() []ProjectNode      { return u.projects }
-func (u *User) getNode(name string) ProjectNode {
-	for _, node := range u.projects {
-		if node.Name() == name {
-			return node
-		}
-	}
-	return nil
+func (u *User) setNodes(n []ProjectNode)  { u.projects = n }
+func (u *User) getNode(pathName string) ProjectNode {
+	return getNode(u.projects, pathName)
 }
 func (u *User) numNodes(includeArchived bool) int {
 	if includeArchived {
@@ -298,6 +345,7 @@ func (u *User) numNodes(includeArchived bool) int {
 }
 func newUser(u *gl.User) *User {
 	return &User{
+		id:       u.ID,
 		fullname: u.Name,
 		username: u.Username,
 	}
@@ -310,14 +358,26 @@ type Group struct {
 	// to the name containing uppercase letters.
 	fullPath string
 
+	// these are only known for groups that have been fetched from
+	// the API, not for groups that have been created from the path
+	// of a project because the user cannot see the group itself.
+	id          int
+	description string
+	webURL      string
+	visibility  gl.VisibilityValue
+
 	subNodes []ProjectNode
 }
 
 func newGroup(g *gl.Group) *Group {
 	return &Group{
-		name:      g.Name,
-		namespace: extractNamespace(g.FullPath),
-		fullPath:  g.FullPath,
+		name:        g.Name,
+		namespace:   extractNamespace(g.FullPath),
+		fullPath:    g.FullPath,
+		id:          g.ID,
+		description: g.Description,
+		webURL:      g.WebURL,
+		visibility:  g.Visibility,
 	}
 }
 
@@ -328,14 +388,10 @@ func (g *Group) FullPath() Namespace       { return Namespace(g.fullPath) }
 func (g *Group) Depth() int                { return len(g.namespace.elements()) }
 func (g *Group) addNodes(n ...ProjectNode) { g.subNodes = append(g.subNodes, n...) }
 func (g *Group) nodes() []ProjectNode      { return g.subNodes }
+func (g *Group) setNodes(n []ProjectNode)  { g.subNodes = n }
 
-func (g *Group) getNode(name string) ProjectNode {
-	for _, node := range g.nodes() {
-		if node.Name() == name {
-			return node
-		}
-	}
-	return nil
+func (g *Group) getNode(pathName string) ProjectNode {
+	return getNode(g.subNodes, pathName)
 }
 
 func (g *Group) numNodes(includeArchived bool) int {
@@ -355,6 +411,18 @@ func (g *Group) numNodes(includeArchived bool) int {
 	return len(g.subNodes) - archived
 }
 
+// getNode returns the node whose path is pathName, which is the last
+// element of its full path. Names may differ from the path, e.g. a
+// group "My Group" may have the path "my-group".
+func getNode(nodes []ProjectNode, pathName string) ProjectNode {
+	for _, node := range nodes {
+		if strings.EqualFold(path.Base(node.FullPath().String()), pathName) {
+			return node
+		}
+	}
+	return nil
+}
+
 type Project struct {
 	gp *gl.Project
 }
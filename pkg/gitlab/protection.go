@@ -0,0 +1,157 @@
+package gitlab
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Protection guards an instance against changes through a context.
+type Protection string
+
+const (
+	// Unprotected allows all requests.
+	Unprotected Protection = "none"
+	// ConfirmWrites asks for a confirmation before modifying requests.
+	ConfirmWrites Protection = "confirm"
+	// ReadOnly refuses all modifying requests.
+	ReadOnly Protection = "read-only"
+)
+
+// Protections are all protections, the least strict first.
+var Protections = []Protection{Unprotected, ConfirmWrites, ReadOnly}
+
+// ParseProtection parses a protection. An empty string is Unprotected.
+func ParseProtection(s string) (Protection, error) {
+	if s == "" {
+		return Unprotected, nil
+	}
+
+	for _, p := range Protections {
+		if string(p) == s {
+			return p, nil
+		}
+	}
+	return "", errors.Errorf("unknown protection %q, must be one of %v", s, Protections)
+}
+
+// Stricter returns the stricter one of both protections.
+func (p Protection) Stricter(other Protection) Protection {
+	if p.level() >= other.level() {
+		return p
+	}
+	return other
+}
+
+// level returns the strictness of the protection. Unknown protections
+// are the strictest.
+func (p Protection) level() int {
+	if p == "" {
+		return 0
+	}
+
+	for i, known := range Protections {
+		if p == known {
+			return i
+		}
+	}
+	return len(Protections)
+}
+
+// ConfirmFunc asks the user whether a modifying request may be sent.
+// The request is described by its method and path.
+type ConfirmFunc func(context, request string) (bool, error)
+
+// ProtectionError is returned for modifying requests that have been
+// refused because of the protection of the context.
+type ProtectionError struct {
+	Context    string
+	Protection Protection
+	Request    string
+}
+
+func (e *ProtectionError) Error() string {
+	if e.Protection == ConfirmWrites {
+		return fmt.Sprintf("context %v requires confirmation for changes, %v has not been confirmed", e.Context, e.Request)
+	}
+	return fmt.Sprintf("context %v is %v, refusing %v", e.Context, e.Protection, e.Request)
+}
+
+// Guard enforces the protection of a context on the requests to its
+// instance. Requests that only read (GET, HEAD, OPTIONS) are always
+// allowed, as are requests for OAuth2 tokens.
+type Guard struct {
+	// Context is the name of the context, used in errors.
+	Context    string
+	Protection Protection
+	// Confirm is asked before the first modifying request if changes
+	// need to be confirmed. Further requests are allowed without asking
+	// again. Without it, modifying requests are refused.
+	Confirm ConfirmFunc
+
+	mu        sync.Mutex
+	confirmed bool
+}
+
+// Transport returns a RoundTripper that checks every request against
+// the guard before passing it to next.
+func (g *Guard) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &guardTransport{guard: g, next: next}
+}
+
+type guardTransport struct {
+	guard *Guard
+	next  http.RoundTripper
+}
+
+func (t *guardTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.guard.check(req); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
+
+func (g *Guard) check(req *http.Request) error {
+	switch {
+	case req.Method == http.MethodGet, req.Method == http.MethodHead, req.Method == http.MethodOptions:
+		return nil
+	case strings.HasSuffix(req.URL.Path, "/oauth/token"):
+		// authentication, not a change.
+		return nil
+	}
+
+	request := fmt.Sprintf("%v %v", req.Method, req.URL.Path)
+
+	switch g.Protection {
+	case "", Unprotected:
+		return nil
+	case ConfirmWrites:
+		// concurrent requests must not ask more than once.
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		if g.confirmed {
+			return nil
+		}
+
+		if g.Confirm != nil {
+			ok, err := g.Confirm(g.Context, request)
+			if err != nil {
+				return errors.Wrapf(err, "could not confirm %v", request)
+			}
+			g.confirmed = ok
+		}
+
+		if g.confirmed {
+			return nil
+		}
+	}
+
+	return &ProtectionError{Context: g.Context, Protection: g.Protection, Request: request}
+}
@@ -0,0 +1,111 @@
+package gitlab
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	gl "github.com/xanzy/go-gitlab"
+)
+
+func TestGuard(t *testing.T) {
+	var writes int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/projects/1/repository/branches", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			writes++
+			fmt.Fprint(w, `{"name": "feature"}`)
+			return
+		}
+		fmt.Fprint(w, `[{"name": "master"}]`)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		protection Protection
+		answer     bool
+		writes     int
+		err        bool
+	}{
+		{"", false, 2, false},
+		{Unprotected, false, 2, false},
+		{ConfirmWrites, true, 2, false},
+		{ConfirmWrites, false, 0, true},
+		{ReadOnly, true, 0, true},
+		{"unknown", true, 0, true},
+	}
+
+	for _, tt := range tests {
+		writes = 0
+		asked := 0
+
+		guard := &Guard{
+			Context:    "production",
+			Protection: tt.protection,
+			Confirm: func(context, request string) (bool, error) {
+				asked++
+				return tt.answer, nil
+			},
+		}
+
+		cl, err := gl.NewClient("token", gl.WithBaseURL(srv.URL), gl.WithHTTPClient(&http.Client{
+			Transport: guard.Transport(nil),
+		}))
+		if err != nil {
+			t.Fatalf("could not create client: %v", err)
+		}
+
+		if _, _, err := cl.Branches.ListBranches(1, nil); err != nil {
+			t.Errorf("%v: reading has been refused: %v", tt.protection, err)
+		}
+
+		var protErr *ProtectionError
+		for i := 0; i < 2; i++ {
+			_, _, err = cl.Branches.CreateBranch(1, &gl.CreateBranchOptions{Branch: gl.String("feature"), Ref: gl.String("master")})
+			if (err != nil) != tt.err {
+				t.Errorf("%v: error not correct. expected error=%v, got=%v", tt.protection, tt.err, err)
+			}
+
+			if err != nil && !errors.As(err, &protErr) {
+				t.Errorf("%v: expected a protection error, got=%v", tt.protection, err)
+			}
+		}
+
+		if writes != tt.writes {
+			t.Errorf("%v: number of writes not correct. expected=%v, got=%v", tt.protection, tt.writes, writes)
+		}
+
+		// a confirmation is only asked for once.
+		if tt.protection == ConfirmWrites && tt.answer && asked != 1 {
+			t.Errorf("%v: confirmation has been asked %v times", tt.protection, asked)
+		}
+
+		if protErr != nil && protErr.Context != "production" {
+			t.Errorf("%v: error does not name the context. got=%v", tt.protection, protErr)
+		}
+	}
+}
+
+func TestParseProtection(t *testing.T) {
+	for _, s := range []string{"", "none", "confirm", "read-only"} {
+		if _, err := ParseProtection(s); err != nil {
+			t.Errorf("could not parse protection %q: %v", s, err)
+		}
+	}
+
+	if _, err := ParseProtection("readonly"); err == nil {
+		t.Errorf("expected error for unknown protection")
+	}
+
+	if p := ConfirmWrites.Stricter(ReadOnly); p != ReadOnly {
+		t.Errorf("stricter protection not correct. expected=%v, got=%v", ReadOnly, p)
+	}
+
+	if p := Protection("").Stricter(ConfirmWrites); p != ConfirmWrites {
+		t.Errorf("stricter protection not correct. expected=%v, got=%v", ConfirmWrites, p)
+	}
+}
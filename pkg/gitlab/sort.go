@@ -0,0 +1,197 @@
+package gitlab
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SortField is the field by which the nodes of a tree are sorted.
+type SortField string
+
+const (
+	// SortByName sorts by the name of the nodes, which is the default.
+	SortByName SortField = "name"
+	// SortByPath sorts by the full path of the nodes.
+	SortByPath SortField = "path"
+	// SortByLastActivity sorts by the last activity, most recent first.
+	SortByLastActivity SortField = "last-activity"
+	// SortByCreated sorts by the creation date, newest first.
+	SortByCreated SortField = "created"
+	// SortByStars sorts by the number of stars, most starred first.
+	SortByStars SortField = "stars"
+	// SortBySize sorts by the storage size, largest first. The size is
+	// only known if the projects have been fetched with statistics.
+	SortBySize SortField = "size"
+)
+
+// SortFields are all fields by which nodes can be sorted.
+var SortFields = []SortField{SortByName, SortByPath, SortByLastActivity, SortByCreated, SortByStars, SortBySize}
+
+// ParseSortField parses a sort field. An empty string is SortByName.
+func ParseSortField(s string) (SortField, error) {
+	if s == "" {
+		return SortByName, nil
+	}
+
+	for _, f := range SortFields {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", errors.Errorf("unknown sort field %q, must be one of %v", s, SortFields)
+}
+
+// GroupOrder places the groups of a level relative to its projects.
+type GroupOrder string
+
+const (
+	// GroupsMixed sorts groups and projects together, which is the default.
+	GroupsMixed GroupOrder = "mixed"
+	// GroupsFirst lists the groups before the projects.
+	GroupsFirst GroupOrder = "first"
+	// GroupsLast lists the groups after the projects.
+	GroupsLast GroupOrder = "last"
+)
+
+// GroupOrders are all orders of groups.
+var GroupOrders = []GroupOrder{GroupsMixed, GroupsFirst, GroupsLast}
+
+// ParseGroupOrder parses a group order. An empty string is GroupsMixed.
+func ParseGroupOrder(s string) (GroupOrder, error) {
+	if s == "" {
+		return GroupsMixed, nil
+	}
+
+	for _, o := range GroupOrders {
+		if string(o) == s {
+			return o, nil
+		}
+	}
+	return "", errors.Errorf("unknown group order %q, must be one of %v", s, GroupOrders)
+}
+
+// SortOptions configure Sort. The zero value sorts by name, with
+// groups and projects mixed.
+type SortOptions struct {
+	Field SortField
+	// Reverse reverses the order of the field. Groups stay first or
+	// last if they are ordered.
+	Reverse bool
+	Groups  GroupOrder
+}
+
+// NeedsStatistics returns true if the projects must be fetched with
+// statistics to be sorted.
+func (o SortOptions) NeedsStatistics() bool {
+	return o.Field == SortBySize
+}
+
+// sortKey holds the values of a node that it can be sorted by. The
+// key of a group aggregates the keys of all nodes below it: it has
+// the latest times and the sum of stars and sizes.
+type sortKey struct {
+	lastActivity time.Time
+	created      time.Time
+	stars        int
+	size         int64
+}
+
+func (k *sortKey) add(other sortKey) {
+	if other.lastActivity.After(k.lastActivity) {
+		k.lastActivity = other.lastActivity
+	}
+	if other.created.After(k.created) {
+		k.created = other.created
+	}
+	k.stars += other.stars
+	k.size += other.size
+}
+
+// Sort sorts every level of the tree below root.
+func Sort(root ProjectNode, opts SortOptions) {
+	keys := make(map[ProjectNode]sortKey)
+
+	var sortLevel func(p ProjectNode) sortKey
+	sortLevel = func(p ProjectNode) sortKey {
+		var key sortKey
+
+		switch n := p.(type) {
+		case *Project:
+			if n.gp.LastActivityAt != nil {
+				key.lastActivity = *n.gp.LastActivityAt
+			}
+			if n.gp.CreatedAt != nil {
+				key.created = *n.gp.CreatedAt
+			}
+			key.stars = n.gp.StarCount
+			if n.gp.Statistics != nil {
+				key.size = n.gp.Statistics.StorageSize
+			}
+
+		case noder:
+			for _, sub := range n.nodes() {
+				key.add(sortLevel(sub))
+			}
+			sortLevelNodes(n.nodes(), keys, opts)
+		}
+
+		keys[p] = key
+		return key
+	}
+
+	sortLevel(root)
+}
+
+// sortLevelNodes sorts the nodes of a single level with the keys,
+// which must be known for all of them.
+func sortLevelNodes(s []ProjectNode, keys map[ProjectNode]sortKey, opts SortOptions) {
+	less := func(a, b ProjectNode) bool {
+		ka, kb := keys[a], keys[b]
+
+		switch opts.Field {
+		case SortByPath:
+			if a.FullPath() != b.FullPath() {
+				return a.FullPath() < b.FullPath()
+			}
+		case SortByLastActivity:
+			if !ka.lastActivity.Equal(kb.lastActivity) {
+				return ka.lastActivity.After(kb.lastActivity)
+			}
+		case SortByCreated:
+			if !ka.created.Equal(kb.created) {
+				return ka.created.After(kb.created)
+			}
+		case SortByStars:
+			if ka.stars != kb.stars {
+				return ka.stars > kb.stars
+			}
+		case SortBySize:
+			if ka.size != kb.size {
+				return ka.size > kb.size
+			}
+		}
+		return a.Name() < b.Name()
+	}
+
+	rank := func(p ProjectNode) int {
+		_, isGroup := p.(noder)
+		switch {
+		case opts.Groups == GroupsFirst && !isGroup, opts.Groups == GroupsLast && isGroup:
+			return 1
+		}
+		return 0
+	}
+
+	sort.SliceStable(s, func(i, j int) bool {
+		if ri, rj := rank(s[i]), rank(s[j]); ri != rj {
+			return ri < rj
+		}
+
+		if opts.Reverse {
+			return less(s[j], s[i])
+		}
+		return less(s[i], s[j])
+	})
+}
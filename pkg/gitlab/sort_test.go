@@ -0,0 +1,60 @@
+package gitlab
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSort(t *testing.T) {
+	tests := []struct {
+		opts     SortOptions
+		expected []string
+	}{
+		{SortOptions{}, []string{"api", "build", "buck", "shared", "tools", "myproject"}},
+		{SortOptions{Reverse: true}, []string{"myproject", "build", "shared", "tools", "buck", "api"}},
+		{SortOptions{Groups: GroupsFirst}, []string{"build", "shared", "tools", "buck", "api", "myproject"}},
+		{SortOptions{Groups: GroupsLast, Reverse: true}, []string{"myproject", "api", "build", "buck", "shared", "tools"}},
+		// the group has the latest activity of its projects.
+		{SortOptions{Field: SortByLastActivity}, []string{"build", "shared", "tools", "buck", "myproject", "api"}},
+		{SortOptions{Field: SortByCreated}, []string{"api", "build", "shared", "tools", "buck", "myproject"}},
+		{SortOptions{Field: SortByStars}, []string{"api", "build", "buck", "shared", "tools", "myproject"}},
+		// the group has the sum of the sizes of its projects.
+		{SortOptions{Field: SortBySize}, []string{"build", "buck", "shared", "tools", "myproject", "api"}},
+		{SortOptions{Field: SortBySize, Reverse: true}, []string{"api", "myproject", "build", "shared", "tools", "buck"}},
+	}
+
+	for _, tt := range tests {
+		root := testTree()
+		Sort(root, tt.opts)
+
+		var names []string
+		_ = Walk(root, func(p ProjectNode) error {
+			if p != root {
+				names = append(names, p.Name())
+			}
+			return nil
+		})
+
+		if !reflect.DeepEqual(names, tt.expected) {
+			t.Errorf("order for %+v not correct. expected=%v, got=%v", tt.opts, tt.expected, names)
+		}
+	}
+}
+
+func TestParseSortOptions(t *testing.T) {
+	if f, err := ParseSortField(""); err != nil || f != SortByName {
+		t.Errorf("default sort field not correct. got=%v, err=%v", f, err)
+	}
+
+	if _, err := ParseSortField("updated"); err == nil {
+		t.Errorf("expected error for unknown sort field")
+	}
+
+	if o, err := ParseGroupOrder("first"); err != nil || o != GroupsFirst {
+		t.Errorf("group order not correct. got=%v, err=%v", o, err)
+	}
+
+	if _, err := ParseGroupOrder("top"); err == nil {
+		t.Errorf("expected error for unknown group order")
+	}
+}
@@ -0,0 +1,107 @@
+package gitlab
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Statistics are the storage statistics of a project, or the totals
+// of all projects below a group or user.
+type Statistics struct {
+	RepositorySize   int64 `json:"repositorySize"`
+	LFSObjectsSize   int64 `json:"lfsObjectsSize"`
+	JobArtifactsSize int64 `json:"jobArtifactsSize"`
+	CommitCount      int   `json:"commitCount"`
+	// Partial is set for totals that leave out projects whose
+	// statistics are not known, so they are lower bounds.
+	Partial bool `json:"partial,omitempty"`
+}
+
+func (s *Statistics) add(other Statistics) {
+	s.RepositorySize += other.RepositorySize
+	s.LFSObjectsSize += other.LFSObjectsSize
+	s.JobArtifactsSize += other.JobArtifactsSize
+	s.CommitCount += other.CommitCount
+}
+
+// NodeStatistics returns the statistics of a project, or the totals of
+// all projects below a group or user. It returns false if the statistics
+// of none of the projects are known, as they are only fetched on request
+// and only for users with at least the reporter role. If only some of
+// them are known, the totals are marked as Partial.
+func NodeStatistics(p ProjectNode) (Statistics, bool) {
+	switch n := p.(type) {
+	case *Project:
+		if n.gp.Statistics == nil {
+			return Statistics{}, false
+		}
+		return Statistics{
+			RepositorySize:   n.gp.Statistics.RepositorySize,
+			LFSObjectsSize:   n.gp.Statistics.LfsObjectsSize,
+			JobArtifactsSize: n.gp.Statistics.JobArtifactsSize,
+			CommitCount:      n.gp.Statistics.CommitCount,
+		}, true
+
+	case noder:
+		var (
+			total          Statistics
+			known, unknown bool
+		)
+		_ = Walk(n, func(sub ProjectNode) error {
+			if _, ok := sub.(*Project); !ok {
+				return nil
+			}
+
+			if s, ok := NodeStatistics(sub); ok {
+				total.add(s)
+				known = true
+			} else {
+				unknown = true
+			}
+			return nil
+		})
+		total.Partial = known && unknown
+		return total, known
+	}
+
+	return Statistics{}, false
+}
+
+// statisticsColumns returns the formatted statistics of the node for
+// PrintProject, or dashes if they are not known. Partial totals are
+// prefixed with "≥".
+func statisticsColumns(p ProjectNode) []string {
+	s, ok := NodeStatistics(p)
+	if !ok {
+		return []string{"-", "-", "-", "-"}
+	}
+
+	columns := []string{
+		formatSize(s.RepositorySize),
+		formatSize(s.LFSObjectsSize),
+		formatSize(s.JobArtifactsSize),
+		strconv.Itoa(s.CommitCount),
+	}
+
+	if s.Partial {
+		for i := range columns {
+			columns[i] = "≥ " + columns[i]
+		}
+	}
+	return columns
+}
+
+// formatSize formats a size in bytes with binary prefixes, e.g. 1.5 MiB.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
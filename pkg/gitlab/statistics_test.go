@@ -0,0 +1,87 @@
+package gitlab
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNodeStatistics(t *testing.T) {
+	root := testTree()
+
+	s, ok := NodeStatistics(root)
+	expected := Statistics{RepositorySize: 3584, LFSObjectsSize: 3 * 1024 * 1024, JobArtifactsSize: 1536, CommitCount: 18, Partial: true}
+	if !ok || s != expected {
+		t.Errorf("root statistics not correct. expected=%+v, got=%+v (known=%v)", expected, s, ok)
+	}
+
+	build := root.(noder).getNode("build").(noder)
+	if s, ok := NodeStatistics(build); !ok || s.CommitCount != 8 || s.Partial {
+		t.Errorf("group statistics not correct. got=%+v (known=%v)", s, ok)
+	}
+
+	if _, ok := NodeStatistics(root.(noder).getNode("api")); ok {
+		t.Errorf("statistics of project without statistics are known")
+	}
+
+	if _, ok := NodeStatistics(&Group{name: "empty"}); ok {
+		t.Errorf("statistics of empty group are known")
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1024:            "1.0 KiB",
+		1536:            "1.5 KiB",
+		3 * 1024 * 1024: "3.0 MiB",
+		5 << 40:         "5.0 TiB",
+	}
+
+	for size, expected := range tests {
+		if s := formatSize(size); s != expected {
+			t.Errorf("size %v not formatted correctly. expected=%q, got=%q", size, expected, s)
+		}
+	}
+}
+
+func TestPrintProjectStatistics(t *testing.T) {
+	actual := PrintProject(testTree(), PrintOptions{PrintStatistics: true})
+
+	// the whitespace is important because of padding
+	expected := `name             type      group                       repository   lfs         artifacts   commits
+----             ----      -----                       ----------   ---         ---------   -------
+mygroup          group     test                        ≥ 3.5 KiB    ≥ 3.0 MiB   ≥ 1.5 KiB   ≥ 18
+├─ api           project   test/mygroup                -            -           -           -
+├─ build         group     test/mygroup                1.5 KiB      3.0 MiB     0 B         8
+│  └─ shared     group     test/mygroup/build          1.0 KiB      3.0 MiB     0 B         5
+│     └─ tools   project   test/mygroup/build/shared   1.0 KiB      3.0 MiB     0 B         5
+└─ myproject     project   test/mygroup                2.0 KiB      0 B         1.5 KiB     10
+`
+
+	if actual != expected {
+		t.Errorf("PrintProject output differs. expected=\n%sgot=\n%s", expected, actual)
+	}
+}
+
+func TestEncodeProjectStatistics(t *testing.T) {
+	var b bytes.Buffer
+	err := EncodeProject(&b, testTree(), EncodeOptions{PrintOptions: PrintOptions{PrintStatistics: true}, Format: CSVOutput})
+	if err != nil {
+		t.Fatalf("could not encode csv: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 7 {
+		t.Fatalf("number of csv lines not correct. expected=7, got=%v", len(lines))
+	}
+
+	if expected := "1,group,test/mygroup,mygroup,false,my group,public,https://gitlab.com/groups/test/mygroup,,,3584,3145728,1536,18,true"; lines[1] != expected {
+		t.Errorf("csv line not correct. expected=%q, got=%q", expected, lines[1])
+	}
+
+	if expected := "5,project,test/mygroup/api,api,false,,,,,,,,,,"; lines[2] != expected {
+		t.Errorf("csv line not correct. expected=%q, got=%q", expected, lines[2])
+	}
+}
@@ -0,0 +1,178 @@
+package gitlab
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	gl "github.com/xanzy/go-gitlab"
+)
+
+// TokenInfo describes the authenticated user and the token that is used.
+type TokenInfo struct {
+	Username, Name string
+
+	// the following fields are only set if the client authenticates
+	// with a personal (or project / group) access token.
+	TokenID   int
+	TokenName string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+// IsAccessToken returns true if the info about the access token
+// (scopes, expiry) is known.
+func (t *TokenInfo) IsAccessToken() bool { return t.TokenID != 0 }
+
+// ScopeRequirement describes the token scopes that a feature of the
+// CLI needs. Having any one of the scopes is sufficient.
+type ScopeRequirement struct {
+	Feature string
+	Scopes  []string
+}
+
+// ScopeRequirements lists the features that need specific scopes.
+var ScopeRequirements = []ScopeRequirement{
+	{Feature: "listing projects (project list)", Scopes: []string{"api", "read_api"}},
+	{Feature: "cloning projects (project clone)", Scopes: []string{"read_repository", "write_repository"}},
+	{Feature: "creating merge requests", Scopes: []string{"api"}},
+}
+
+// MissingScopes returns the requirements that are not met by the token.
+// If the scopes of the token are not known, nothing is returned.
+func (t *TokenInfo) MissingScopes() []ScopeRequirement {
+	if !t.IsAccessToken() {
+		return nil
+	}
+
+	var missing []ScopeRequirement
+NextRequirement:
+	for _, req := range ScopeRequirements {
+		for _, s := range req.Scopes {
+			if t.hasScope(s) {
+				continue NextRequirement
+			}
+		}
+		missing = append(missing, req)
+	}
+	return missing
+}
+
+func (t *TokenInfo) hasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpiresWithin returns true if the token expires in less than d.
+func (t *TokenInfo) ExpiresWithin(d time.Duration) bool {
+	return t.ExpiresAt != nil && time.Until(*t.ExpiresAt) < d
+}
+
+// personalAccessToken is returned by the personal access token API,
+// which is not implemented by go-gitlab.
+type personalAccessToken struct {
+	ID        int         `json:"id"`
+	Name      string      `json:"name"`
+	Revoked   bool        `json:"revoked"`
+	Active    bool        `json:"active"`
+	Scopes    []string    `json:"scopes"`
+	ExpiresAt *gl.ISOTime `json:"expires_at"`
+	Token     string      `json:"token"`
+}
+
+// TokenInfo returns information about the authenticated user and token. This
+// also validates the credentials, returning an error if they are invalid.
+func (c *Client) TokenInfo(ctx context.Context) (*TokenInfo, error) {
+	u, _, err := c.c.Users.CurrentUser(gl.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get current user")
+	}
+
+	info := &TokenInfo{
+		Username: u.Username,
+		Name:     u.Name,
+	}
+
+	pat, resp, err := c.doTokenRequest(ctx, http.MethodGet, "personal_access_tokens/self")
+	if err != nil {
+		// the endpoint does not exist on older Gitlab versions and is not
+		// available for other authentication methods like OAuth2.
+		if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnauthorized) {
+			return info, nil
+		}
+		return nil, errors.Wrapf(err, "could not get access token information")
+	}
+
+	info.TokenID = pat.ID
+	info.TokenName = pat.Name
+	info.Scopes = pat.Scopes
+	if pat.ExpiresAt != nil {
+		t := time.Time(*pat.ExpiresAt)
+		info.ExpiresAt = &t
+	}
+
+	return info, nil
+}
+
+func (c *Client) doTokenRequest(ctx context.Context, method, path string) (*personalAccessToken, *gl.Response, error) {
+	req, err := c.c.NewRequest(method, path, nil, []gl.RequestOptionFunc{gl.WithContext(ctx)})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pat := new(personalAccessToken)
+	resp, err := c.c.Do(req, pat)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return pat, resp, nil
+}
+
+// String returns a human-readable summary of the scopes.
+func (r ScopeRequirement) String() string {
+	return r.Feature + " needs scope " + strings.Join(r.Scopes, " or ")
+}
+
+// RotateToken rotates the access token that the client authenticates
+// with, returning the new token and its expiry. The old token is revoked
+// immediately. If expiresAt is nil, Gitlab chooses the expiry.
+func (c *Client) RotateToken(ctx context.Context, expiresAt *time.Time) (string, *time.Time, error) {
+	type rotateOptions struct {
+		ExpiresAt *gl.ISOTime `json:"expires_at,omitempty"`
+	}
+
+	opts := &rotateOptions{}
+	if expiresAt != nil {
+		t := gl.ISOTime(*expiresAt)
+		opts.ExpiresAt = &t
+	}
+
+	req, err := c.c.NewRequest(http.MethodPost, "personal_access_tokens/self/rotate", opts, []gl.RequestOptionFunc{gl.WithContext(ctx)})
+	if err != nil {
+		return "", nil, err
+	}
+
+	pat := new(personalAccessToken)
+	if _, err := c.c.Do(req, pat); err != nil {
+		return "", nil, errors.Wrapf(err, "could not rotate token")
+	}
+
+	if pat.Token == "" {
+		return "", nil, errors.New("no token returned by Gitlab")
+	}
+
+	var expiry *time.Time
+	if pat.ExpiresAt != nil {
+		t := time.Time(*pat.ExpiresAt)
+		expiry = &t
+	}
+
+	return pat.Token, expiry, nil
+}
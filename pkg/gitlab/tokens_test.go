@@ -0,0 +1,117 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gl "github.com/xanzy/go-gitlab"
+)
+
+func TestTokenInfo(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/user", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 1, "username": "tommyknows", "name": "Ramon"}`)
+	})
+	mux.HandleFunc("/api/v4/personal_access_tokens/self", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 42, "name": "cli", "scopes": ["read_api"], "expires_at": "2030-01-02", "active": true}`)
+	})
+
+	cl := newTestClient(t, mux)
+
+	info, err := New(cl, "").TokenInfo(context.Background())
+	if err != nil {
+		t.Fatalf("could not get token info: %v", err)
+	}
+
+	if info.Username != "tommyknows" || info.TokenName != "cli" {
+		t.Errorf("token info not correct. expected=%q/%q, got=%q/%q", "tommyknows", "cli", info.Username, info.TokenName)
+	}
+
+	if info.ExpiresAt == nil || info.ExpiresAt.Format("2006-01-02") != "2030-01-02" {
+		t.Errorf("expiry not correct. expected=%v, got=%v", "2030-01-02", info.ExpiresAt)
+	}
+
+	missing := info.MissingScopes()
+	if len(missing) != 2 {
+		t.Fatalf("number of missing scopes not correct. expected=%v, got=%v", 2, len(missing))
+	}
+
+	if missing[0].Scopes[0] != "read_repository" || missing[1].Scopes[0] != "api" {
+		t.Errorf("missing scopes not correct. got=%v", missing)
+	}
+}
+
+func TestTokenInfoWithoutAccessToken(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/user", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 1, "username": "tommyknows", "name": "Ramon"}`)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	cl, err := gl.NewOAuthClient("token", gl.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+
+	info, err := New(cl, "").TokenInfo(context.Background())
+	if err != nil {
+		t.Fatalf("could not get token info: %v", err)
+	}
+
+	if info.IsAccessToken() || info.MissingScopes() != nil {
+		t.Errorf("expected no access token information. got=%+v", info)
+	}
+}
+
+func TestTokenInfoExpiresWithin(t *testing.T) {
+	tomorrow := time.Now().Add(24 * time.Hour)
+
+	tests := []struct {
+		expiresAt *time.Time
+		within    time.Duration
+		expected  bool
+	}{
+		{nil, 24 * time.Hour, false},
+		{&tomorrow, 48 * time.Hour, true},
+		{&tomorrow, 12 * time.Hour, false},
+	}
+
+	for _, tt := range tests {
+		info := &TokenInfo{ExpiresAt: tt.expiresAt}
+		if e := info.ExpiresWithin(tt.within); e != tt.expected {
+			t.Errorf("expiry within %v not correct for %v. expected=%v, got=%v", tt.within, tt.expiresAt, tt.expected, e)
+		}
+	}
+}
+
+func TestRotateToken(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/personal_access_tokens/self/rotate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		fmt.Fprint(w, `{"id": 43, "name": "cli", "scopes": ["api"], "expires_at": "2030-02-03", "token": "new-token"}`)
+	})
+
+	cl := newTestClient(t, mux)
+
+	token, expiry, err := New(cl, "").RotateToken(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("could not rotate token: %v", err)
+	}
+
+	if token != "new-token" {
+		t.Errorf("token not correct. expected=%q, got=%q", "new-token", token)
+	}
+
+	if expiry == nil || expiry.Format("2006-01-02") != "2030-02-03" {
+		t.Errorf("expiry not correct. expected=%v, got=%v", "2030-02-03", expiry)
+	}
+}
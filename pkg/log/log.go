@@ -11,8 +11,10 @@ var (
 	format = logging.MustStringFormatter(`%{color}%{level:.4s} ▶%{color:reset} %{message}`)
 )
 
+// Setup configures the logger with the given level. Log messages are
+// written to stderr, so that they don't mix with the output on stdout.
 func Setup(level string) {
-	b := logging.NewLogBackend(os.Stdout, "", 0)
+	b := logging.NewLogBackend(os.Stderr, "", 0)
 	bformatter := logging.NewBackendFormatter(b, format)
 	logging.SetBackend(bformatter)
 
@@ -39,3 +41,6 @@ func Debugf(format string, args ...interface{}) {
 func Infof(format string, args ...interface{}) {
 	log.Infof(format, args...)
 }
+func Warningf(format string, args ...interface{}) {
+	log.Warningf(format, args...)
+}
@@ -0,0 +1,273 @@
+/*
+Package picker implements an interactive fuzzy finder on the
+terminal. The user types a query, the items are filtered while
+typing and the selected item is returned.
+
+	> backend/api
+	  backend/api-gateway
+	  2/14
+	namespace: ba/ap
+*/
+package picker
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// ErrCanceled is returned if the user aborted the selection.
+var ErrCanceled = errors.New("selection canceled")
+
+// maxVisible is the number of matches that are shown at once.
+const maxVisible = 10
+
+const (
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyBackspace = 8
+	keyLineFeed  = 10
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyEnter     = 13
+	keyCtrlU     = 21
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// IsInteractive returns true if the user can be prompted, which is the
+// case if both stdin and stderr are terminals.
+func IsInteractive() bool {
+	return terminal.IsTerminal(int(os.Stdin.Fd())) && terminal.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// Pick lets the user choose one of the items on the terminal. The picker
+// is drawn on stderr, so that stdout can still be piped.
+func Pick(prompt string, items []string) (string, error) {
+	if len(items) == 0 {
+		return "", errors.New("nothing to pick from")
+	}
+
+	fd := int(os.Stdin.Fd())
+	state, err := terminal.MakeRaw(fd)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not set up terminal")
+	}
+	defer terminal.Restore(fd, state)
+
+	return run(os.Stdin, os.Stderr, prompt, items, maxVisible)
+}
+
+// run reads keys from r and draws the picker to w until an item is
+// selected. The terminal is expected to be in raw mode.
+func run(r io.Reader, w io.Writer, prompt string, items []string, height int) (string, error) {
+	in := bufio.NewReader(r)
+
+	var query []rune
+	selected, lines := 0, 0
+	for {
+		matches := Filter(string(query), items)
+		if selected >= len(matches) {
+			selected = len(matches) - 1
+		}
+		if selected < 0 {
+			selected = 0
+		}
+
+		lines = render(w, lines, prompt, string(query), matches, len(items), selected, height)
+
+		key, _, err := in.ReadRune()
+		if err == io.EOF {
+			clear(w, lines)
+			return "", ErrCanceled
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "could not read input")
+		}
+
+		switch key {
+		case keyEnter, keyLineFeed:
+			if len(matches) == 0 {
+				continue
+			}
+			clear(w, lines)
+			return matches[selected], nil
+
+		case keyCtrlC, keyCtrlD:
+			clear(w, lines)
+			return "", ErrCanceled
+
+		case keyEscape:
+			// a lone escape cancels, arrow keys are sent as escape sequences.
+			if in.Buffered() == 0 {
+				clear(w, lines)
+				return "", ErrCanceled
+			}
+			switch readEscapeSequence(in) {
+			case "[A":
+				selected--
+			case "[B":
+				selected++
+			}
+
+		case keyCtrlP:
+			selected--
+
+		case keyCtrlN:
+			selected++
+
+		case keyBackspace, keyDelete:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				selected = 0
+			}
+
+		case keyCtrlU:
+			query = nil
+			selected = 0
+
+		default:
+			if unicode.IsPrint(key) {
+				query = append(query, key)
+				selected = 0
+			}
+		}
+	}
+}
+
+// readEscapeSequence reads the rest of an escape sequence, e.g. "[A"
+// for the up arrow.
+func readEscapeSequence(in *bufio.Reader) string {
+	var seq []rune
+	for in.Buffered() > 0 {
+		r, _, err := in.ReadRune()
+		if err != nil {
+			break
+		}
+		seq = append(seq, r)
+		// sequences end with a letter or a tilde.
+		if len(seq) > 1 && (unicode.IsLetter(r) || r == '~') {
+			break
+		}
+	}
+	return string(seq)
+}
+
+// render draws the matches and the prompt, replacing the previous
+// drawing of the given number of lines. It returns the number of lines
+// above the prompt.
+func render(w io.Writer, prev int, prompt, query string, matches []string, total, selected, height int) int {
+	var b strings.Builder
+	moveUp(&b, prev)
+
+	start := 0
+	if selected >= height {
+		start = selected - height + 1
+	}
+	end := start + height
+	if end > len(matches) {
+		end = len(matches)
+	}
+
+	for i := start; i < end; i++ {
+		marker := "  "
+		if i == selected {
+			marker = "> "
+		}
+		// in raw mode, a newline does not return the carriage.
+		fmt.Fprintf(&b, "%v%v\r\n", marker, matches[i])
+	}
+	fmt.Fprintf(&b, "  %v/%v\r\n", len(matches), total)
+	fmt.Fprintf(&b, "%v %v", prompt, query)
+
+	fmt.Fprint(w, b.String())
+	return end - start + 1
+}
+
+// clear removes the drawing of the picker.
+func clear(w io.Writer, lines int) {
+	var b strings.Builder
+	moveUp(&b, lines)
+	fmt.Fprint(w, b.String())
+}
+
+func moveUp(b *strings.Builder, lines int) {
+	if lines > 0 {
+		fmt.Fprintf(b, "\x1b[%dA", lines)
+	}
+	// return to the start of the line and erase everything below.
+	b.WriteString("\r\x1b[J")
+}
+
+// Filter returns the items that match the query, the best match first.
+// Items with the same score keep their order. An empty query matches
+// every item and keeps the order of all items.
+func Filter(query string, items []string) []string {
+	type match struct {
+		item  string
+		score int
+	}
+
+	if query == "" {
+		return append([]string(nil), items...)
+	}
+
+	var matches []match
+	for _, item := range items {
+		if score, ok := Match(query, item); ok {
+			matches = append(matches, match{item, score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	res := make([]string, len(matches))
+	for i, m := range matches {
+		res[i] = m.item
+	}
+	return res
+}
+
+// Match reports whether all characters of the query appear in the
+// candidate in the same order, ignoring case. The returned score is
+// higher if the characters are consecutive or start a word, and if the
+// candidate is shorter.
+func Match(query, candidate string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	c := []rune(strings.ToLower(candidate))
+
+	score, qi, prev := 0, 0, -2
+	for ci := 0; ci < len(c) && qi < len(q); ci++ {
+		if c[ci] != q[qi] {
+			continue
+		}
+
+		score += 10
+		if ci == prev+1 {
+			score += 15
+		}
+		if ci == 0 || isSeparator(c[ci-1]) {
+			score += 10
+		}
+		prev = ci
+		qi++
+	}
+
+	if qi < len(q) {
+		return 0, false
+	}
+	return score - len(c), true
+}
+
+func isSeparator(r rune) bool {
+	return strings.ContainsRune("/-_. ", r)
+}
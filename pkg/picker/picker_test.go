@@ -0,0 +1,110 @@
+package picker
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		query     string
+		candidate string
+		match     bool
+	}{
+		{"", "anything", true},
+		{"bap", "backend/api", true},
+		{"BaAp", "backend/api", true},
+		{"api/b", "backend/api", false},
+		{"backend/apis", "backend/api", false},
+	}
+
+	for _, tt := range tests {
+		if _, ok := Match(tt.query, tt.candidate); ok != tt.match {
+			t.Errorf("match of %q in %q not correct. expected=%v, got=%v", tt.query, tt.candidate, tt.match, ok)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	items := []string{
+		"tommyknows/bank-app",
+		"backend/api-gateway",
+		"backend/api",
+		"frontend/app",
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"", items},
+		{"api", []string{"backend/api", "backend/api-gateway"}},
+		{"bapp", []string{"tommyknows/bank-app"}},
+		{"fa", []string{"frontend/app"}},
+		{"xyz", []string{}},
+	}
+
+	for _, tt := range tests {
+		got := Filter(tt.query, items)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("filter %q not correct. expected=%v, got=%v", tt.query, tt.expected, got)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	items := []string{"dev", "staging", "production", "prod-eu"}
+
+	tests := []struct {
+		keys     string
+		expected string
+		err      error
+	}{
+		{"\r", "dev", nil},
+		{"prod\r", "prod-eu", nil},
+		{"prod\x1b[B\r", "production", nil},
+		{"prod\x0e\x0e\x10\r", "prod-eu", nil},
+		{"\x1b[A\x1b[A\r", "dev", nil},
+		{"xx\x7f\x7fst\r", "staging", nil},
+		{"xyz\r\x15s\r", "staging", nil},
+		{"prod\x03", "", ErrCanceled},
+		{"prod\x1b", "", ErrCanceled},
+		{"prod", "", ErrCanceled},
+	}
+
+	for _, tt := range tests {
+		got, err := run(strings.NewReader(tt.keys), ioutil.Discard, "context:", items, 2)
+		if err != tt.err {
+			t.Errorf("keys %q: error not correct. expected=%v, got=%v", tt.keys, tt.err, err)
+			continue
+		}
+
+		if got != tt.expected {
+			t.Errorf("keys %q: selection not correct. expected=%q, got=%q", tt.keys, tt.expected, got)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	var b bytes.Buffer
+	matches := []string{"a", "b", "c", "d"}
+
+	lines := render(&b, 0, "context:", "q", matches, 10, 3, 2)
+	if lines != 3 {
+		t.Errorf("number of lines not correct. expected=3, got=%v", lines)
+	}
+
+	expected := "\r\x1b[J  c\r\n> d\r\n  4/10\r\ncontext: q"
+	if b.String() != expected {
+		t.Errorf("output not correct. expected=%q, got=%q", expected, b.String())
+	}
+
+	b.Reset()
+	render(&b, lines, "context:", "", nil, 10, 0, 2)
+	if !strings.HasPrefix(b.String(), "\x1b[3A\r\x1b[J") {
+		t.Errorf("previous output not cleared. got=%q", b.String())
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Package secret implements a small file-based store for secrets
+like access tokens. The file is encrypted with a key that is
+derived from a passphrase, so secrets are never stored in
+plaintext on disk.
+*/
+package secret
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// PassphraseEnv is the environment variable that is checked for
+// the passphrase before prompting for it.
+const PassphraseEnv = "GITLAB_CLI_PASSPHRASE"
+
+// ErrWrongPassphrase is returned if a store cannot be decrypted.
+var ErrWrongPassphrase = errors.New("could not decrypt secret store, wrong passphrase?")
+
+const (
+	saltLength  = 32
+	nonceLength = 24
+	keyLength   = 32
+)
+
+// Store holds secrets by name. Changes are only persisted by calling Write.
+type Store struct {
+	path    string
+	salt    []byte
+	key     *[keyLength]byte
+	secrets map[string]string
+}
+
+// file is the on-disk representation of a store.
+type file struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// Open opens the store at path, decrypting it with the passphrase.
+// If the file does not exist, an empty store is returned.
+func Open(path string, passphrase []byte) (*Store, error) {
+	s := &Store{
+		path:    path,
+		secrets: make(map[string]string),
+	}
+
+	cont, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		s.salt = make([]byte, saltLength)
+		if _, err := io.ReadFull(rand.Reader, s.salt); err != nil {
+			return nil, errors.Wrapf(err, "could not generate salt")
+		}
+
+		s.key, err = deriveKey(passphrase, s.salt)
+		return s, err
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read secret store")
+	}
+
+	var f file
+	if err := json.Unmarshal(cont, &f); err != nil {
+		return nil, errors.Wrapf(err, "could not unmarshal secret store")
+	}
+
+	if len(f.Nonce) != nonceLength {
+		return nil, errors.New("secret store is corrupt: invalid nonce")
+	}
+
+	s.salt = f.Salt
+	s.key, err = deriveKey(passphrase, s.salt)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [nonceLength]byte
+	copy(nonce[:], f.Nonce)
+
+	data, ok := secretbox.Open(nil, f.Data, &nonce, s.key)
+	if !ok {
+		return nil, ErrWrongPassphrase
+	}
+
+	if err := json.Unmarshal(data, &s.secrets); err != nil {
+		return nil, errors.Wrapf(err, "could not unmarshal secrets")
+	}
+
+	return s, nil
+}
+
+// Get returns the secret with the given name.
+func (s *Store) Get(name string) (string, bool) {
+	secret, ok := s.secrets[name]
+	return secret, ok
+}
+
+// Set sets the secret with the given name.
+func (s *Store) Set(name, secret string) { s.secrets[name] = secret }
+
+// Delete removes the secret with the given name.
+func (s *Store) Delete(name string) { delete(s.secrets, name) }
+
+// Write encrypts and writes the store to disk.
+func (s *Store) Write() error {
+	data, err := json.Marshal(s.secrets)
+	if err != nil {
+		return errors.Wrapf(err, "could not marshal secrets")
+	}
+
+	var nonce [nonceLength]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return errors.Wrapf(err, "could not generate nonce")
+	}
+
+	cont, err := json.Marshal(file{
+		Salt:  s.salt,
+		Nonce: nonce[:],
+		Data:  secretbox.Seal(nil, data, &nonce, s.key),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not marshal secret store")
+	}
+
+	return ioutil.WriteFile(s.path, cont, 0600)
+}
+
+func deriveKey(passphrase, salt []byte) (*[keyLength]byte, error) {
+	k, err := scrypt.Key(passphrase, salt, 1<<15, 8, 1, keyLength)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not derive key from passphrase")
+	}
+
+	var key [keyLength]byte
+	copy(key[:], k)
+	return &key, nil
+}
+
+// ReadPassphrase returns the passphrase from the environment or, if
+// it is not set there, prompts for it on the terminal.
+func ReadPassphrase(prompt string) ([]byte, error) {
+	if p, ok := os.LookupEnv(PassphraseEnv); ok {
+		return []byte(p), nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return nil, errors.Errorf("no terminal to prompt for passphrase, set %v", PassphraseEnv)
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	p, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read passphrase")
+	}
+
+	return p, nil
+}
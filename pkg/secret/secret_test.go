@@ -0,0 +1,50 @@
+package secret
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitlab-cli-secret")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "secrets")
+
+	s, err := Open(path, []byte("passphrase"))
+	if err != nil {
+		t.Fatalf("could not open new store: %v", err)
+	}
+
+	s.Set("gitlab.com", "my-secret-token")
+	if err := s.Write(); err != nil {
+		t.Fatalf("could not write store: %v", err)
+	}
+
+	cont, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read store: %v", err)
+	}
+	if strings.Contains(string(cont), "my-secret-token") {
+		t.Errorf("secret is stored in plaintext: %s", cont)
+	}
+
+	s, err = Open(path, []byte("passphrase"))
+	if err != nil {
+		t.Fatalf("could not open existing store: %v", err)
+	}
+
+	if secret, ok := s.Get("gitlab.com"); !ok || secret != "my-secret-token" {
+		t.Errorf("secret not correct. expected=%q, got=%q", "my-secret-token", secret)
+	}
+
+	if _, err := Open(path, []byte("wrong")); err != ErrWrongPassphrase {
+		t.Errorf("opening with wrong passphrase returned wrong error. expected=%v, got=%v", ErrWrongPassphrase, err)
+	}
+}